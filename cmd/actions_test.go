@@ -2,12 +2,19 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Serares/pscanner/scan"
 )
@@ -38,6 +45,11 @@ func setup(t *testing.T, hosts []string, initList bool) (string, func()) {
 	}
 }
 
+// addNoInfo adds hosts without metadata
+func addNoInfo(out io.Writer, hostsFile string, args []string) error {
+	return addAction(out, hostsFile, args, scan.HostInfo{})
+}
+
 func TestActions(t *testing.T) {
 	hosts := []string{
 		"host1",
@@ -55,7 +67,7 @@ func TestActions(t *testing.T) {
 		args:           hosts,
 		expectedOut:    "Added host: host1\nAdded host: host2\nAdded host: host3\n",
 		initList:       false,
-		actionFunction: addAction,
+		actionFunction: addNoInfo,
 	},
 		{
 			name:           "ListAction",
@@ -121,7 +133,7 @@ func TestIntegration(t *testing.T) {
 		expectedOut += fmt.Sprintln()
 	}
 	// Add hosts to the list
-	if err := addAction(&stdout, tf, hosts); err != nil {
+	if err := addAction(&stdout, tf, hosts, scan.HostInfo{}); err != nil {
 		t.Fatalf("Expected no error, got %q\n", err)
 	}
 	// List hosts
@@ -137,7 +149,7 @@ func TestIntegration(t *testing.T) {
 		t.Fatalf("Expected no error, got %q\n", err)
 	}
 
-	if err := scanAction(&stdout, tf, &scan.ScanCfg{}); err != nil {
+	if err := scanAction(context.Background(), &stdout, tf, nil, nil, outputOptions{}, &scan.ScanCfg{}); err != nil {
 		t.Fatalf("expected no error, got %q\n", err)
 	}
 	// Test integration output
@@ -172,17 +184,25 @@ func TestScanAction(t *testing.T) {
 		}
 	}
 
-	expectedOut := fmt.Sprintln("TCP scan: ")
-	expectedOut += fmt.Sprintf("localhost:\n")
-	expectedOut += fmt.Sprintf("\t%s: open\n", ports[0])
-	expectedOut += fmt.Sprintf("\t%s: closed\n", ports[1])
+	// ports are printed sorted by port number
+	portLines := []string{
+		fmt.Sprintf("\t\t%s: open\n", ports[0]),
+		fmt.Sprintf("\t\t%s: closed\n", ports[1]),
+	}
+	if p0, p1 := portNumber(t, ports[0]), portNumber(t, ports[1]); p0 > p1 {
+		portLines[0], portLines[1] = portLines[1], portLines[0]
+	}
+
+	expectedOut := fmt.Sprintf("localhost:\n")
+	expectedOut += fmt.Sprintln("\tTCP scan:")
+	expectedOut += strings.Join(portLines, "")
 	expectedOut += fmt.Sprintln()
 	expectedOut += fmt.Sprintln("unknownhostoutthere: Host not found")
 	expectedOut += fmt.Sprintln()
 	// Define var to capture scan output
 	var out bytes.Buffer
 	// Execute scan and capture output
-	if err := scanAction(&out, tf, &scan.ScanCfg{Ports: ports, Tcp: true}); err != nil {
+	if err := scanAction(context.Background(), &out, tf, nil, nil, outputOptions{}, &scan.ScanCfg{Ports: ports, Tcp: true}); err != nil {
 		t.Fatalf("Expected no error, got %q\n", err)
 	}
 	// Test scan output
@@ -190,3 +210,559 @@ func TestScanAction(t *testing.T) {
 		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
 	}
 }
+
+func portNumber(t *testing.T, port string) int {
+	t.Helper()
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDiscoverAction(t *testing.T) {
+	hosts := []string{
+		"localhost",
+		"unknownhostoutthere",
+	}
+	tf, cleanup := setup(t, hosts, true)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedOut := "localhost: up\n"
+	expectedOut += "unknownhostoutthere: Host not found\n"
+
+	var out bytes.Buffer
+	cfg := &scan.ScanCfg{DiscoveryPorts: []string{portStr}}
+	if err := discoverAction(context.Background(), &out, tf, nil, nil, cfg); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+}
+
+func TestScanActionTags(t *testing.T) {
+	tf, cleanup := setup(t, nil, false)
+	defer cleanup()
+
+	var out bytes.Buffer
+	if err := addAction(&out, tf, []string{"host1"}, scan.HostInfo{Tags: []string{"prod"}}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	if err := addAction(&out, tf, []string{"host2"}, scan.HostInfo{Tags: []string{"dev"}}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	out.Reset()
+	if err := scanAction(context.Background(), &out, tf, nil, []string{"prod"}, outputOptions{}, &scan.ScanCfg{}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	expectedOut := "host1: Host not found\n\n"
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+}
+
+func TestGroupActions(t *testing.T) {
+	hosts := []string{
+		"host1",
+		"host2",
+		"host3",
+	}
+	tf, cleanup := setup(t, hosts, true)
+	defer cleanup()
+
+	var out bytes.Buffer
+	steps := []struct {
+		action func(io.Writer, string, []string) error
+		args   []string
+	}{
+		{groupCreateAction, []string{"dmz", "web", "db"}},
+		{groupAddAction, []string{"web", "host2", "host3"}},
+		{groupAddAction, []string{"dmz", "host1", "@web"}},
+		{groupRemoveAction, []string{"web", "host3"}},
+		{groupRemoveAction, []string{"db"}},
+		{groupListAction, nil},
+		{groupListAction, []string{"dmz"}},
+	}
+	for _, s := range steps {
+		if err := s.action(&out, tf, s.args); err != nil {
+			t.Fatalf("Expected no error, got %q\n", err)
+		}
+	}
+
+	expectedOut := "Created group: dmz\nCreated group: web\nCreated group: db\n"
+	expectedOut += "Added to group web: host2\nAdded to group web: host3\n"
+	expectedOut += "Added to group dmz: host1\nAdded to group dmz: @web\n"
+	expectedOut += "Removed from group web: host3\n"
+	expectedOut += "Deleted group: db\n"
+	expectedOut += "dmz: host1 @web\nweb: host2\n"
+	expectedOut += "host1\nhost2\n"
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+
+	out.Reset()
+	if err := scanAction(context.Background(), &out, tf, []string{"web"}, nil, outputOptions{}, &scan.ScanCfg{}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	expectedOut = "host2: Host not found\n\n"
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+}
+
+func TestImportAction(t *testing.T) {
+	tf, cleanup := setup(t, []string{"host1"}, true)
+	defer cleanup()
+
+	in := strings.NewReader("host1\nhost2, host3\n[::1\n")
+	var out bytes.Buffer
+	err := importAction(in, &out, tf, nil, scan.InventoryAuto, scan.HostInfo{})
+	if !errors.Is(err, scan.ErrInvalidHost) {
+		t.Errorf("Expected error %q, got %v\n", scan.ErrInvalidHost, err)
+	}
+
+	expectedOut := "Skipped host: host1\nAdded host: host2\nAdded host: host3\n"
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+
+	// the valid hosts are saved
+	out.Reset()
+	if err := listAction(&out, tf, nil); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	expectedOut = "host1\nhost2\nhost3\n"
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+}
+
+func TestAddActionNormalize(t *testing.T) {
+	tf, cleanup := setup(t, nil, false)
+	defer cleanup()
+
+	var out bytes.Buffer
+	args := append([]string{"Foo.COM."}, cleanHosts([]string{"https://bar.com:8443/x"})...)
+	if err := addAction(&out, tf, args, scan.HostInfo{}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	expectedOut := "Added host: foo.com\nAdded host: bar.com\n"
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+
+	for _, h := range []string{"foo.com:22", "http://foo.com/"} {
+		err := addAction(&out, tf, []string{h}, scan.HostInfo{})
+		if !errors.Is(err, scan.ErrInvalidHost) || !strings.Contains(err.Error(), "--lenient") {
+			t.Errorf("Expected error %q with a hint to use --lenient, got %v\n", scan.ErrInvalidHost, err)
+		}
+	}
+}
+
+func TestScanActionJSON(t *testing.T) {
+	tf, cleanup := setup(t, []string{"localhost", "unknownhostoutthere"}, true)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &scan.ScanCfg{Ports: []string{portStr}, Tcp: true}
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := scanAction(context.Background(), &out, tf, nil, nil, outputOptions{format: outputJSON}, cfg); err != nil {
+			t.Fatalf("Expected no error, got %q\n", err)
+		}
+
+		var report scan.Report
+		if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+			t.Fatalf("Expected a JSON report, got %q: %s\n", out.String(), err)
+		}
+		if report.SchemaVersion != scan.SchemaVersion || len(report.Results) != 2 {
+			t.Fatalf("Expected 2 results of schema %d, got %+v\n", scan.SchemaVersion, report)
+		}
+		if ps := report.Results[0].PortStates; len(ps) != 1 || ps[0].State != scan.StateOpen {
+			t.Errorf("Expected port %s open, got %v\n", portStr, ps)
+		}
+		if !report.Results[1].NotFound || report.Results[1].Err == nil {
+			t.Errorf("Expected host not found with an error, got %+v\n", report.Results[1])
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := scanAction(context.Background(), &out, tf, nil, nil, outputOptions{format: outputNDJSON}, cfg); err != nil {
+			t.Fatalf("Expected no error, got %q\n", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Expected 2 lines, got %q\n", out.String())
+		}
+		for i, host := range []string{"localhost", "unknownhostoutthere"} {
+			var rec scan.Record
+			if err := json.Unmarshal([]byte(lines[i]), &rec); err != nil {
+				t.Fatalf("Expected a JSON record, got %q: %s\n", lines[i], err)
+			}
+			if rec.SchemaVersion != scan.SchemaVersion || rec.Result.Host != host {
+				t.Errorf("Expected record of %s, got %+v\n", host, rec)
+			}
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := scanAction(context.Background(), &out, tf, nil, nil, outputOptions{format: "xml"}, cfg); err == nil {
+			t.Errorf("Expected an error for an unknown format\n")
+		}
+	})
+}
+
+func TestScanActionCSV(t *testing.T) {
+	tf, cleanup := setup(t, []string{"localhost", "unknownhostoutthere"}, true)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &scan.ScanCfg{Ports: []string{portStr}, Tcp: true, IPVersion: 4}
+
+	testCases := []struct {
+		name        string
+		opts        outputOptions
+		expectedOut string
+	}{
+		{"CSV", outputOptions{format: outputCSV},
+			"host,address,protocol,port,state,service\n" +
+				"localhost,127.0.0.1,tcp," + portStr + ",open,\n" +
+				"unknownhostoutthere,,,,,\n"},
+		{"TSVColumns", outputOptions{format: outputTSV, columns: []string{"host", "status", "port", "state"}, noHeader: true},
+			"localhost\tup\t" + portStr + "\topen\n" +
+				"unknownhostoutthere\tnot found\t\t\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := scanAction(context.Background(), &out, tf, nil, nil, tc.opts, cfg); err != nil {
+				t.Fatalf("Expected no error, got %q\n", err)
+			}
+			if out.String() != tc.expectedOut {
+				t.Errorf("Expected output %q, got %q\n", tc.expectedOut, out.String())
+			}
+		})
+	}
+
+	var out bytes.Buffer
+	opts := outputOptions{format: outputCSV, columns: []string{"host", "color"}}
+	if err := scanAction(context.Background(), &out, tf, nil, nil, opts, cfg); err == nil {
+		t.Errorf("Expected an error for an unknown column\n")
+	}
+}
+
+func TestScanActionNmapXML(t *testing.T) {
+	tf, cleanup := setup(t, []string{"localhost", "unknownhostoutthere"}, true)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &scan.ScanCfg{Ports: []string{portStr}, Tcp: true, IPVersion: 4}
+
+	var out bytes.Buffer
+	opts := outputOptions{format: outputNmapXML, args: "pscanner scan -T"}
+	if err := scanAction(context.Background(), &out, tf, nil, nil, opts, cfg); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "<?xml") || !strings.Contains(out.String(), "<!DOCTYPE nmaprun>") {
+		t.Errorf("Expected a XML document with the nmaprun doctype, got %q\n", out.String())
+	}
+
+	// the elements in the order of the DTD
+	last := 0
+	for _, e := range []string{"<scaninfo ", `<verbose level="0">`, `<debugging level="0">`, "<host ", "<runstats>"} {
+		i := strings.Index(out.String(), e)
+		if i < last {
+			t.Fatalf("Expected %s after the previous elements, got %q\n", e, out.String())
+		}
+		last = i
+	}
+
+	var run nmapRun
+	if err := xml.Unmarshal(out.Bytes(), &run); err != nil {
+		t.Fatalf("Expected a nmap XML document, got %q: %s\n", out.String(), err)
+	}
+	if run.Args != opts.args || run.ScanInfo[0].Services != portStr {
+		t.Errorf("Expected args %q and services %s, got %+v\n", opts.args, portStr, run)
+	}
+	// the host not found is left out
+	if len(run.Hosts) != 1 || run.RunStats.Hosts.Up != 1 {
+		t.Fatalf("Expected 1 host up, got %+v\n", run.Hosts)
+	}
+	h := run.Hosts[0]
+	if h.Address.Addr != "127.0.0.1" || h.Hostnames.Hostnames[0].Name != "localhost" {
+		t.Errorf("Expected localhost at 127.0.0.1, got %+v\n", h)
+	}
+	if p := h.Ports.Ports[0]; strconv.Itoa(p.PortID) != portStr || p.State.State != "open" {
+		t.Errorf("Expected port %s open, got %+v\n", portStr, p)
+	}
+
+	// our own importer reads the output back
+	hosts, err := scan.ParseInventory(&out, "", scan.InventoryAuto)
+	if err != nil || len(hosts) != 1 || hosts[0] != "127.0.0.1" {
+		t.Errorf("Expected to import 127.0.0.1, got %q %v\n", hosts, err)
+	}
+}
+
+func TestNmapPortList(t *testing.T) {
+	ports := []int{22, 80, 81, 82, 443, 8080, 8081}
+	expected := "22,80-82,443,8080-8081"
+	if list := nmapPortList(ports); list != expected {
+		t.Errorf("Expected %q, got %q\n", expected, list)
+	}
+}
+
+func TestReportAction(t *testing.T) {
+	start := time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC)
+	results := []scan.Results{
+		{Host: "web|1", Status: scan.HostUp, Start: start, Elapsed: time.Second, PortStates: []scan.PortState{
+			{Port: 80, Protocol: scan.ProtocolTcp, State: scan.StateOpen, Banner: "<script>"},
+			{Port: 8080, Protocol: scan.ProtocolTcp, State: scan.StateOpen, Banner: "a|b\r\nc`d"},
+			{Port: 6379, Protocol: scan.ProtocolTcp, State: scan.StateOpen},
+			{Port: 3306, Protocol: scan.ProtocolTcp, State: scan.StateClosed},
+			{Port: 161, Protocol: scan.ProtocolUdp, State: scan.StateOpenFiltered},
+		}},
+		{Host: "gone", NotFound: true, Err: errors.New("no such host"), Start: start},
+	}
+	report, err := json.Marshal(scan.Report{SchemaVersion: scan.SchemaVersion, Start: start, Elapsed: time.Second, Results: results})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ndjson bytes.Buffer
+	for _, r := range results {
+		if err := json.NewEncoder(&ndjson).Encode(scan.Record{SchemaVersion: scan.SchemaVersion, Result: r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		input     string
+		format    string
+		expectOut []string
+		expectErr bool
+	}{
+		{"HTML", string(report), outputHTML, []string{
+			"<style>",
+			`<td class="num">2</td><td class="num">1</td><td class="num">0</td><td class="num">1</td><td class="num">3</td><td class="num">1</td><td class="num">1</td><td class="num risk">1</td>`,
+			`<tr class="risky"><td class="num">6379</td>`,
+			`<td class="state-open-filtered">open|filtered</td>`,
+			"<code>&lt;script&gt;</code>",
+			"no such host",
+		}, false},
+		{"Markdown", string(report), outputMarkdown, []string{
+			"| 2 | 1 | 0 | 1 | 3 | 1 | 1 | **1** |",
+			"## web\\|1 (up)",
+			"| 6379 | tcp | **open** | redis ⚠ often unauthenticated |  |",
+			"| 3306 | tcp | closed |  |  |",
+			"| 161 | udp | open\\|filtered |  |  |",
+			"| 8080 | tcp | open |  | `a\\|b c'd` |",
+			"## gone (not found)",
+		}, false},
+		{"NDJSON", ndjson.String(), outputMarkdown, []string{"| 2 | 1 | 0 | 1 | 3 | 1 | 1 | **1** |", "took 1s"}, false},
+		{"NewerSchema", `{"schema_version": 99, "results": []}`, outputHTML, nil, true},
+		{"UnknownFormat", string(report), "pdf", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := reportAction(strings.NewReader(tc.input), &out, tc.format)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected error, got nil instead\n")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			for _, s := range tc.expectOut {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("Expected output to contain %q, got %q instead\n", s, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestScanActionTemplate(t *testing.T) {
+	tf, cleanup := setup(t, []string{"localhost"}, true)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		port      string
+		template  string
+		expectOut string
+		expectErr bool
+	}{
+		{"Open", portStr, `{{range .}}{{.Host}} {{join .Addresses ","}}:{{range open .PortStates}} {{.Port}}/{{.State}}{{end}}{{"\n"}}{{end}}`,
+			fmt.Sprintf("localhost 127.0.0.1: %s/open\n", portStr), false},
+		{"Service", "22", `{{range .}}{{range .PortStates}}{{if eq .Port 22}}{{service .}}{{end}}{{end}}{{end}}`, "ssh", false},
+		{"Invalid", portStr, `{{range .}}`, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cfg := &scan.ScanCfg{Ports: []string{tc.port}, Tcp: true, IPVersion: 4}
+			opts := outputOptions{template: tc.template, templateName: "format"}
+			err := scanAction(context.Background(), &out, tf, nil, nil, opts, cfg)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected error, got nil instead\n")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if out.String() != tc.expectOut {
+				t.Errorf("Expected output %q, got %q instead\n", tc.expectOut, out.String())
+			}
+		})
+	}
+}
+
+func TestOutputOptionsValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		opts      outputOptions
+		expectErr bool
+	}{
+		{"Default", outputOptions{columns: defaultColumns}, false},
+		{"UnknownFormat", outputOptions{format: "csvv"}, true},
+		{"UnknownColumn", outputOptions{format: outputCSV, columns: []string{"color"}}, true},
+		{"Template", outputOptions{template: "{{range .}}{{.Host}}{{end}}", templateName: "format"}, false},
+		{"InvalidTemplate", outputOptions{template: "{{", templateName: "format"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.opts.validate(); (err != nil) != tc.expectErr {
+				t.Errorf("Expected error %t, got %v instead\n", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestAddActionUpdate(t *testing.T) {
+	tf, cleanup := setup(t, nil, false)
+	defer cleanup()
+
+	var out bytes.Buffer
+	if err := addAction(&out, tf, []string{"foo.com"}, scan.HostInfo{Tags: []string{"dev"}, Owner: "ops"}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	if err := addAction(&out, tf, []string{"foo.com"}, scan.HostInfo{}); !errors.Is(err, scan.ErrExists) {
+		t.Errorf("Expected error %q, got %v\n", scan.ErrExists, err)
+	}
+
+	out.Reset()
+	if err := addAction(&out, tf, []string{"FOO.com"}, scan.HostInfo{Tags: []string{"prod"}, Ports: "80,443"}); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	if expectedOut := "Updated host: foo.com\n"; out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+
+	hl, err := loadHosts(tf, nil, []string{"prod"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	expected := scan.HostInfo{Tags: []string{"prod"}, Owner: "ops", Ports: "80,443"}
+	if len(hl.Hosts) != 1 || !reflect.DeepEqual(hl.Info["foo.com"], expected) {
+		t.Errorf("Expected foo.com with info %+v, got %q %+v\n", expected, hl.Hosts, hl.Info)
+	}
+}
+
+func TestTSVEscape(t *testing.T) {
+	r := scan.Results{Host: "localhost", PortStates: []scan.PortState{
+		{Port: 22, Protocol: scan.ProtocolTcp, State: scan.StateOpen, Banner: "SSH-2.0 \"x\"\tsrv\r\nmotd\\"},
+	}}
+
+	var out bytes.Buffer
+	rw, err := newResultsWriter(&out, outputOptions{format: outputTSV, columns: []string{"host", "port", "banner"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+	if err := rw.write(r); err != nil {
+		t.Fatalf("Expected no error, got %q\n", err)
+	}
+
+	expectedOut := "host\tport\tbanner\n" + `localhost` + "\t22\t" + `SSH-2.0 "x"\tsrv\r\nmotd\\` + "\n"
+	if out.String() != expectedOut {
+		t.Errorf("Expected output %q, got %q\n", expectedOut, out.String())
+	}
+}
+
+// failWriter fails every write
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestScanActionWriteError(t *testing.T) {
+	tf, cleanup := setup(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, true)
+	defer cleanup()
+
+	cfg := &scan.ScanCfg{Ports: []string{"1"}, Tcp: true, SkipDiscovery: true, HostParallelism: 1}
+	err := scanAction(context.Background(), failWriter{}, tf, nil, nil, outputOptions{format: outputNDJSON}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected the write error, got %v\n", err)
+	}
+}
@@ -22,47 +22,180 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Serares/pscanner/scan"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// resolveTimeout is the time to wait for a host name to resolve
+const resolveTimeout = 5 * time.Second
+
 // addCmd represents the add command
 var addCmd = &cobra.Command{
 	Use:          "add <host1> ....<hostn>",
+	Example:      "pscanner hosts add example.com 10.0.0.0/24 10.0.1.5-40 10.0.*.1\npscanner hosts add --tag prod,web --ports 80,443 web1.example.com",
 	Aliases:      []string{"a"},
 	Args:         cobra.MinimumNArgs(1),
 	SilenceUsage: true,
 	Short:        "Add host(s) to list",
+	Long: `Adds hosts to the list.
+
+	With any of the tag, ports, owner or notes flags
+	the hosts already in the list get those fields updated,
+	the tags replace the tags of the host.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile := viper.GetString("hosts-file")
 
-		return addAction(os.Stdout, hostsFile, args)
+		tags, err := cmd.Flags().GetStringSlice("tag")
+		if err != nil {
+			return err
+		}
+		ports, err := cmd.Flags().GetString("ports")
+		if err != nil {
+			return err
+		}
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		notes, err := cmd.Flags().GetString("notes")
+		if err != nil {
+			return err
+		}
+		lenient, err := cmd.Flags().GetBool("lenient")
+		if err != nil {
+			return err
+		}
+		resolve, err := cmd.Flags().GetBool("resolve")
+		if err != nil {
+			return err
+		}
+		info := scan.HostInfo{Tags: tags, Owner: owner, Notes: notes, Ports: ports}
+
+		if lenient {
+			args = cleanHosts(args)
+		}
+		if resolve {
+			if err := checkResolve(cmd.Context(), newResolver(), args); err != nil {
+				return err
+			}
+		}
+
+		return addAction(os.Stdout, hostsFile, args, info)
 	},
 }
 
 func init() {
 	hostsCmd.AddCommand(addCmd)
+
+	addCmd.Flags().StringSlice("tag", nil, "tags of the hosts, e.g. prod,web")
+	addCmd.Flags().String("ports", "", "ports scanned on the hosts instead of the ports of the scan, e.g. 80,443")
+	addCmd.Flags().String("owner", "", "owner of the hosts")
+	addCmd.Flags().String("notes", "", "notes about the hosts")
+	addCmd.Flags().Bool("lenient", false, "take the host of URLs and host:port pairs")
+	addCmd.Flags().Bool("resolve", false, "reject the host names that don't resolve")
 }
 
-func addAction(out io.Writer, hostsFile string, args []string) error {
-	hl := &scan.HostsList{}
+// cleanHosts takes the hosts out of URLs and host:port pairs
+func cleanHosts(args []string) []string {
+	hosts := make([]string, 0, len(args))
+	for _, h := range args {
+		hosts = append(hosts, scan.CleanHost(h))
+	}
+
+	return hosts
+}
 
-	if err := hl.Load(hostsFile); err != nil {
-		return err
+// normalizeArg normalizes a host of the arguments,
+// the error tells to use --lenient for URLs and host:port pairs
+func normalizeArg(h string) (string, error) {
+	host, err := scan.NormalizeHost(h)
+	if err != nil && scan.CleanHost(h) != strings.TrimSpace(h) {
+		return "", fmt.Errorf("%w, use --lenient to take its host", err)
 	}
 
+	return host, err
+}
+
+// checkResolve checks that all the host names resolve,
+// before locking the hosts file as resolving can be slow
+func checkResolve(ctx context.Context, resolver scan.Resolver, args []string) error {
 	for _, h := range args {
-		if err := hl.Add(h); err != nil {
+		host, err := normalizeArg(h)
+		if err != nil {
 			return err
 		}
 
-		fmt.Fprintln(out, "Added host:", h)
+		lookupCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+		err = scan.CheckResolves(lookupCtx, resolver, host)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addAction(out io.Writer, hostsFile string, args []string, info scan.HostInfo) error {
+	return scan.Update(hostsFile, func(hl *scan.HostsList) error {
+		for _, h := range args {
+			host, err := normalizeArg(h)
+			if err != nil {
+				return err
+			}
+			err = hl.Add(host)
+			if errors.Is(err, scan.ErrExists) {
+				// the metadata of existing hosts is updated
+				updated, changed := mergeInfo(hl.Info[host], info)
+				if !changed {
+					return err
+				}
+				if err := hl.SetInfo(host, updated); err != nil {
+					return err
+				}
+
+				fmt.Fprintln(out, "Updated host:", host)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := hl.SetInfo(host, info); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(out, "Added host:", host)
+		}
+
+		return nil
+	})
+}
+
+// mergeInfo sets the fields of the info that are set in update,
+// it reports false if update has no fields set
+func mergeInfo(info, update scan.HostInfo) (scan.HostInfo, bool) {
+	changed := false
+	if len(update.Tags) > 0 {
+		info.Tags, changed = update.Tags, true
+	}
+	if update.Owner != "" {
+		info.Owner, changed = update.Owner, true
+	}
+	if update.Notes != "" {
+		info.Notes, changed = update.Notes, true
+	}
+	if update.Ports != "" {
+		info.Ports, changed = update.Ports, true
 	}
 
-	return hl.Save(hostsFile)
+	return info, changed
 }
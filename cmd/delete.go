@@ -49,15 +49,13 @@ func init() {
 }
 
 func deleteAction(out io.Writer, hostsFile string, args []string) error {
-	hl := &scan.HostsList{}
-	if err := hl.Load(hostsFile); err != nil {
-		return err
-	}
-	for _, h := range args {
-		if err := hl.Remove(h); err != nil {
-			return err
+	return scan.Update(hostsFile, func(hl *scan.HostsList) error {
+		for _, h := range args {
+			if err := hl.Remove(h); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "Deleted host:", h)
 		}
-		fmt.Fprintln(out, "Deleted host:", h)
-	}
-	return hl.Save(hostsFile)
+		return nil
+	})
 }
@@ -0,0 +1,119 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/Serares/pscanner/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:          "discover",
+	Short:        "Check which hosts are up without scanning their ports",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostsFile := viper.GetString("hosts-file")
+
+		discoveryPorts, err := cmd.Flags().GetStringSlice("discovery-ports")
+		if err != nil {
+			return err
+		}
+		groups, err := cmd.Flags().GetStringSlice("group")
+		if err != nil {
+			return err
+		}
+		tags, err := cmd.Flags().GetStringSlice("tag")
+		if err != nil {
+			return err
+		}
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+
+		cfg := &scan.ScanCfg{
+			DiscoveryPorts: discoveryPorts,
+			TcpTimeout:     timeout,
+			Resolver:       newResolver(),
+			Logger:         newLogger(),
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		return discoverAction(ctx, os.Stdout, hostsFile, groups, tags, cfg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+
+	discoverCmd.Flags().StringSlice("group", nil, "check only the hosts of these groups")
+	discoverCmd.Flags().StringSlice("tag", nil, "check only the hosts with any of these tags")
+	discoverCmd.Flags().StringSlice("discovery-ports", scan.DefaultDiscoveryPorts, "ports pinged to check if a host is up")
+	discoverCmd.Flags().Duration("timeout", scan.DefaultTcpTimeout, "time to wait for an answer to a ping")
+}
+
+func discoverAction(ctx context.Context, w io.Writer, hostsFile string, groups, tags []string, cfg *scan.ScanCfg) error {
+	hl, err := loadHosts(hostsFile, groups, tags)
+	if err != nil {
+		return err
+	}
+
+	// without ports only the discovery runs
+	cfg.Ports = nil
+	cfg.SkipDiscovery = false
+
+	resCh, err := scan.RunContext(ctx, hl, cfg)
+	if err != nil {
+		return err
+	}
+
+	for r := range resCh {
+		status := r.Status.String()
+		if r.NotFound {
+			status = "Host not found"
+		}
+
+		host := r.Host
+		if r.Address != "" {
+			host = fmt.Sprintf("%s (%s)", r.Host, r.Address)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", host, status); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("discovery interrupted: %w", ctx.Err())
+	}
+
+	return nil
+}
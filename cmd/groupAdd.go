@@ -0,0 +1,63 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Serares/pscanner/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// groupAddCmd represents the group add command
+var groupAddCmd = &cobra.Command{
+	Use:          "add <group> <member1>...<membern>",
+	Example:      "pscanner hosts group add dmz host1.example.com @web",
+	Short:        "Add hosts or groups to a group",
+	Aliases:      []string{"a"},
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostsFile := viper.GetString("hosts-file")
+		return groupAddAction(os.Stdout, hostsFile, args)
+	},
+}
+
+func init() {
+	groupCmd.AddCommand(groupAddCmd)
+}
+
+func groupAddAction(out io.Writer, hostsFile string, args []string) error {
+	group := args[0]
+	return scan.Update(hostsFile, func(hl *scan.HostsList) error {
+		for _, m := range args[1:] {
+			if err := hl.AddToGroup(group, m); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Added to group %s: %s\n", group, m)
+		}
+		return nil
+	})
+}
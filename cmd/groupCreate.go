@@ -0,0 +1,61 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Serares/pscanner/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// groupCreateCmd represents the group create command
+var groupCreateCmd = &cobra.Command{
+	Use:          "create <group1>...<groupn>",
+	Short:        "Create empty group(s)",
+	Aliases:      []string{"c"},
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostsFile := viper.GetString("hosts-file")
+		return groupCreateAction(os.Stdout, hostsFile, args)
+	},
+}
+
+func init() {
+	groupCmd.AddCommand(groupCreateCmd)
+}
+
+func groupCreateAction(out io.Writer, hostsFile string, args []string) error {
+	return scan.Update(hostsFile, func(hl *scan.HostsList) error {
+		for _, g := range args {
+			if err := hl.CreateGroup(g); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "Created group:", g)
+		}
+		return nil
+	})
+}
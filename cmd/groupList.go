@@ -0,0 +1,84 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Serares/pscanner/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// groupListCmd represents the group list command
+var groupListCmd = &cobra.Command{
+	Use:     "list [<group>]",
+	Short:   "List the groups, or the hosts of a group",
+	Aliases: []string{"l"},
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostsFile := viper.GetString("hosts-file")
+		return groupListAction(os.Stdout, hostsFile, args)
+	},
+}
+
+func init() {
+	groupCmd.AddCommand(groupListCmd)
+}
+
+// groupListAction lists every group with its members,
+// or all the hosts of a group including the nested groups
+func groupListAction(w io.Writer, hostsFile string, args []string) error {
+	hl := &scan.HostsList{}
+
+	if err := hl.Load(hostsFile); err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		hosts, err := hl.GroupHosts(args[0])
+		if err != nil {
+			return err
+		}
+		for _, h := range hosts {
+			if _, err := fmt.Fprintln(w, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, g := range hl.GroupNames() {
+		line := g + ":"
+		if members := hl.Groups[g]; len(members) > 0 {
+			line += " " + strings.Join(members, " ")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Serares/pscanner/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// groupRemoveCmd represents the group remove command
+var groupRemoveCmd = &cobra.Command{
+	Use:          "remove <group> [<member1>...<membern>]",
+	Short:        "Remove members from a group, or the group without members",
+	Aliases:      []string{"r"},
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostsFile := viper.GetString("hosts-file")
+		return groupRemoveAction(os.Stdout, hostsFile, args)
+	},
+}
+
+func init() {
+	groupCmd.AddCommand(groupRemoveCmd)
+}
+
+func groupRemoveAction(out io.Writer, hostsFile string, args []string) error {
+	group := args[0]
+	return scan.Update(hostsFile, func(hl *scan.HostsList) error {
+		if len(args) == 1 {
+			if err := hl.DeleteGroup(group); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "Deleted group:", group)
+			return nil
+		}
+		for _, m := range args[1:] {
+			if err := hl.RemoveFromGroup(group, m); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Removed from group %s: %s\n", group, m)
+		}
+		return nil
+	})
+}
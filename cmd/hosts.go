@@ -32,8 +32,18 @@ var hostsCmd = &cobra.Command{
 	Long: `Manges hosts list for pscanner
 	
 	Add hosts with the add command
+	Import hosts from inventory files with the import command
 	Delete hosts with the delete command
-	List hosts with the list command.`,
+	List hosts with the list command
+	Manage groups of hosts with the group command.
+
+	The hosts file is a host per line or a YAML or JSON document
+	where every host can have tags, an owner, notes and its own ports:
+
+	hosts:
+	  - host: example.com
+	    tags: [prod, web]
+	    ports: 80,443`,
 }
 
 func init() {
@@ -0,0 +1,152 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Serares/pscanner/scan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import [<file1>...<filen>]",
+	Short: "Import hosts from inventory files or the standard input",
+	Long: `Imports hosts from inventory files, or from the standard input
+	when there are no files or a file is -
+
+	The format of every file is detected from its name and content,
+	or set with the format flag to one of
+	` + inventoryFormatNames() + `
+
+	Hosts already in the list are skipped.`,
+	Example:      "pscanner hosts import inventory.ini ~/.ssh/config\nnmap -sn -oG - 10.0.0.0/24 | pscanner hosts import --format nmap-grep",
+	Aliases:      []string{"i"},
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostsFile := viper.GetString("hosts-file")
+
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		tags, err := cmd.Flags().GetStringSlice("tag")
+		if err != nil {
+			return err
+		}
+
+		return importAction(os.Stdin, os.Stdout, hostsFile, args, scan.InventoryFormat(format), scan.HostInfo{Tags: tags})
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(importCmd)
+
+	importCmd.Flags().String("format", "", "format of the inventories, detected if not set")
+	importCmd.Flags().StringSlice("tag", nil, "tags of the imported hosts, e.g. prod,web")
+}
+
+func inventoryFormatNames() string {
+	names := make([]string, 0, len(scan.InventoryFormats))
+	for _, f := range scan.InventoryFormats {
+		names = append(names, string(f))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// importAction adds the hosts of the inventories to the list,
+// the hosts already in the list are reported and skipped
+// and the other invalid hosts are returned as errors after saving the list
+func importAction(in io.Reader, out io.Writer, hostsFile string, args []string, format scan.InventoryFormat, info scan.HostInfo) error {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	// the inventories are read before locking the hosts file
+	// so a slow standard input doesn't block other commands
+	var hosts []string
+	for _, name := range args {
+		var invHosts []string
+		var err error
+		if name == "-" {
+			invHosts, err = scan.ParseInventory(in, "", format)
+		} else {
+			invHosts, err = parseInventoryFile(name, format)
+		}
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, invHosts...)
+	}
+
+	var errs []error
+	err := scan.Update(hostsFile, func(hl *scan.HostsList) error {
+		for _, h := range hosts {
+			host, err := scan.NormalizeHost(h)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := hl.Add(host); err != nil {
+				if errors.Is(err, scan.ErrExists) {
+					fmt.Fprintln(out, "Skipped host:", host)
+					continue
+				}
+				errs = append(errs, err)
+				continue
+			}
+			if err := hl.SetInfo(host, info); err != nil {
+				return err
+			}
+
+			fmt.Fprintln(out, "Added host:", host)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(errs...)
+}
+
+func parseInventoryFile(name string, format scan.InventoryFormat) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hosts, err := scan.ParseInventory(f, name, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,328 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+// the elements of the nmap XML output, see https://nmap.org/book/nmap-dtd.html
+type nmapRun struct {
+	XMLName          xml.Name       `xml:"nmaprun"`
+	Scanner          string         `xml:"scanner,attr"`
+	Args             string         `xml:"args,attr,omitempty"`
+	Start            int64          `xml:"start,attr"`
+	StartStr         string         `xml:"startstr,attr"`
+	Version          string         `xml:"version,attr"`
+	XMLOutputVersion string         `xml:"xmloutputversion,attr"`
+	ScanInfo         []nmapScanInfo `xml:"scaninfo"`
+	// the DTD requires verbose and debugging before the hosts
+	Verbose   nmapLevel    `xml:"verbose"`
+	Debugging nmapLevel    `xml:"debugging"`
+	Hosts     []nmapHost   `xml:"host"`
+	RunStats  nmapRunStats `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapLevel struct {
+	Level int `xml:"level,attr"`
+}
+
+type nmapHost struct {
+	StartTime int64          `xml:"starttime,attr"`
+	EndTime   int64          `xml:"endtime,attr"`
+	Status    nmapStatus     `xml:"status"`
+	Address   nmapAddress    `xml:"address"`
+	Hostnames *nmapHostnames `xml:"hostnames"`
+	Ports     *nmapPorts     `xml:"ports"`
+}
+
+type nmapStatus struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapHostnames struct {
+	Hostnames []nmapHostname `xml:"hostname"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPorts struct {
+	Ports []nmapPort `xml:"port"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapState    `xml:"state"`
+	Service  *nmapService `xml:"service"`
+	Script   *nmapScript  `xml:"script"`
+}
+
+type nmapState struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+type nmapService struct {
+	Name   string `xml:"name,attr"`
+	Method string `xml:"method,attr"`
+	Conf   int    `xml:"conf,attr"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished `xml:"finished"`
+	Hosts    nmapHosts    `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Summary string `xml:"summary,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHosts struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// nmapTime is the format of the time strings of nmap
+const nmapTime = "Mon Jan _2 15:04:05 2006"
+
+// nmapXMLWriter writes a nmap XML document once the scan ends
+type nmapXMLWriter struct {
+	w    io.Writer
+	args string
+}
+
+func (n *nmapXMLWriter) write(r scan.Results) error {
+	return nil
+}
+
+func (n *nmapXMLWriter) close(results []scan.Results, start time.Time, elapsed time.Duration) error {
+	end := start.Add(elapsed)
+	run := nmapRun{
+		Scanner:          "pscanner",
+		Args:             n.args,
+		Start:            start.Unix(),
+		StartStr:         start.Format(nmapTime),
+		Version:          rootCmd.Version,
+		XMLOutputVersion: "1.05",
+		ScanInfo:         nmapScanInfos(results),
+	}
+
+	up, down := 0, 0
+	for _, r := range results {
+		// nmap leaves out the hosts that don't resolve
+		if r.NotFound {
+			continue
+		}
+		h := nmapHostOf(r)
+		if h.Status.State == "up" {
+			up++
+		} else {
+			down++
+		}
+		run.Hosts = append(run.Hosts, h)
+	}
+
+	run.RunStats = nmapRunStats{
+		Finished: nmapFinished{
+			Time:    end.Unix(),
+			TimeStr: end.Format(nmapTime),
+			Elapsed: fmt.Sprintf("%.2f", elapsed.Seconds()),
+			Summary: fmt.Sprintf("pscanner done at %s; %d IP addresses (%d hosts up) scanned in %.2f seconds",
+				end.Format(nmapTime), up+down, up, elapsed.Seconds()),
+			Exit: "success",
+		},
+		Hosts: nmapHosts{Up: up, Down: down, Total: up + down},
+	}
+
+	if _, err := io.WriteString(n.w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(n.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(run); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(n.w, "\n")
+	return err
+}
+
+// nmapScanInfos lists the ports scanned with every protocol
+func nmapScanInfos(results []scan.Results) []nmapScanInfo {
+	ports := map[string]map[int]bool{}
+	for _, r := range results {
+		for _, p := range r.PortStates {
+			if ports[p.Protocol] == nil {
+				ports[p.Protocol] = map[int]bool{}
+			}
+			ports[p.Protocol][p.Port] = true
+		}
+	}
+
+	var infos []nmapScanInfo
+	for _, protocol := range []string{scan.ProtocolTcp, scan.ProtocolUdp} {
+		if len(ports[protocol]) == 0 {
+			continue
+		}
+		list := make([]int, 0, len(ports[protocol]))
+		for p := range ports[protocol] {
+			list = append(list, p)
+		}
+		sort.Ints(list)
+
+		scanType := "connect"
+		if protocol == scan.ProtocolUdp {
+			scanType = "udp"
+		}
+		infos = append(infos, nmapScanInfo{
+			Type:        scanType,
+			Protocol:    protocol,
+			NumServices: len(list),
+			Services:    nmapPortList(list),
+		})
+	}
+
+	return infos
+}
+
+// nmapPortList writes sorted ports like nmap, e.g. 22,80-90,443
+func nmapPortList(ports []int) string {
+	var parts []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(ports[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func nmapHostOf(r scan.Results) nmapHost {
+	address := r.Address
+	if address == "" && len(r.Addresses) > 0 {
+		address = r.Addresses[0]
+	}
+
+	h := nmapHost{
+		StartTime: r.Start.Unix(),
+		EndTime:   r.Start.Add(r.Elapsed).Unix(),
+		Address:   nmapAddress{Addr: address, AddrType: "ipv4"},
+	}
+	if ip, err := netip.ParseAddr(address); err == nil && ip.Unmap().Is6() {
+		h.Address.AddrType = "ipv6"
+	}
+	if r.Host != address {
+		h.Hostnames = &nmapHostnames{Hostnames: []nmapHostname{{Name: r.Host, Type: "user"}}}
+	}
+
+	switch r.Status {
+	case scan.HostUp:
+		h.Status = nmapStatus{State: "up", Reason: "syn-ack"}
+	case scan.HostDown:
+		h.Status = nmapStatus{State: "down", Reason: "no-response"}
+		return h
+	default:
+		// like nmap -Pn when the discovery is skipped
+		h.Status = nmapStatus{State: "up", Reason: "user-set"}
+	}
+
+	h.Ports = &nmapPorts{}
+	for _, p := range r.PortStates {
+		port := nmapPort{
+			Protocol: p.Protocol,
+			PortID:   p.Port,
+			State:    nmapState{State: p.State.String(), Reason: nmapReason(p)},
+		}
+		if p.Service != "" {
+			port.Service = &nmapService{Name: p.Service, Method: "probed", Conf: 10}
+		}
+		if p.Banner != "" {
+			port.Script = &nmapScript{ID: "banner", Output: p.Banner}
+		}
+		h.Ports.Ports = append(h.Ports.Ports, port)
+	}
+
+	return h
+}
+
+// nmapReason returns the reason nmap gives for the state of a port
+func nmapReason(p scan.PortState) string {
+	switch p.State {
+	case scan.StateOpen:
+		if p.Protocol == scan.ProtocolUdp {
+			return "udp-response"
+		}
+		return "syn-ack"
+	case scan.StateClosed:
+		if p.Protocol == scan.ProtocolUdp {
+			return "port-unreach"
+		}
+		return "conn-refused"
+	default:
+		return "no-response"
+	}
+}
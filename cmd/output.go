@@ -0,0 +1,365 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+// formats of the scan results
+const (
+	outputText    = "text"
+	outputJSON    = "json"
+	outputNDJSON  = "ndjson"
+	outputCSV     = "csv"
+	outputTSV     = "tsv"
+	outputNmapXML = "nmap-xml"
+	// the HTML and Markdown reports are also rendered by the report command
+	outputHTML     = "html"
+	outputMarkdown = "markdown"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputNDJSON, outputCSV, outputTSV, outputNmapXML,
+	outputHTML, outputMarkdown}
+
+// outputOptions tell how to write the scan results
+type outputOptions struct {
+	format string
+	// columns are the columns of the CSV and TSV formats,
+	// defaultColumns if not set
+	columns []string
+	// noHeader leaves out the header row of the CSV and TSV formats
+	noHeader bool
+	// args is the command line written in the nmap XML format
+	args string
+	// template is a Go template executed with the results
+	// instead of the format, templateName names it in the errors
+	template     string
+	templateName string
+}
+
+// validate checks the format, the columns and the template,
+// so that they can be checked before creating the output file
+func (o outputOptions) validate() error {
+	if o.template != "" {
+		_, err := newTemplate(o.templateName, o.template)
+		return err
+	}
+
+	found := o.format == ""
+	for _, f := range outputFormats {
+		found = found || f == o.format
+	}
+	if !found {
+		return fmt.Errorf("unknown output format %q, expected one of %v", o.format, outputFormats)
+	}
+
+	for _, c := range o.columns {
+		if _, ok := columns[c]; !ok {
+			return fmt.Errorf("unknown column %q, expected some of %s", c, strings.Join(columnNames, ", "))
+		}
+	}
+
+	return nil
+}
+
+// columns maps the columns of the CSV and TSV formats
+// to the values of a port of a host
+var columns = map[string]func(r scan.Results, p *scan.PortState) string{
+	"host": func(r scan.Results, p *scan.PortState) string { return r.Host },
+	"address": func(r scan.Results, p *scan.PortState) string {
+		if r.Address != "" || len(r.Addresses) == 0 {
+			return r.Address
+		}
+		return r.Addresses[0]
+	},
+	"status": func(r scan.Results, p *scan.PortState) string {
+		if r.NotFound {
+			return "not found"
+		}
+		return r.Status.String()
+	},
+	"protocol": portColumn(func(p *scan.PortState) string { return p.Protocol }),
+	"port":     portColumn(func(p *scan.PortState) string { return strconv.Itoa(p.Port) }),
+	"state":    portColumn(func(p *scan.PortState) string { return p.State.String() }),
+	"service":  portColumn(func(p *scan.PortState) string { return p.Service }),
+	"banner":   portColumn(func(p *scan.PortState) string { return p.Banner }),
+	"attempts": portColumn(func(p *scan.PortState) string { return strconv.Itoa(p.Attempts) }),
+	"elapsed_ms": func(r scan.Results, p *scan.PortState) string {
+		elapsed := r.Elapsed
+		if p != nil {
+			elapsed = p.Elapsed
+		}
+		return strconv.FormatFloat(float64(elapsed)/float64(time.Millisecond), 'f', 3, 64)
+	},
+	"error": func(r scan.Results, p *scan.PortState) string {
+		err := r.Err
+		if p != nil {
+			err = p.Err
+		}
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	},
+}
+
+// columnNames are the names of the columns in the order of the help
+var columnNames = []string{"host", "address", "status", "protocol", "port", "state",
+	"service", "banner", "attempts", "elapsed_ms", "error"}
+
+var defaultColumns = []string{"host", "address", "protocol", "port", "state", "service"}
+
+// portColumn returns the column of a port value,
+// empty for the hosts without ports
+func portColumn(value func(p *scan.PortState) string) func(r scan.Results, p *scan.PortState) string {
+	return func(r scan.Results, p *scan.PortState) string {
+		if p == nil {
+			return ""
+		}
+		return value(p)
+	}
+}
+
+// resultsWriter writes the scan results in one of the formats
+type resultsWriter interface {
+	// write is called with every host as soon as it's scanned
+	write(r scan.Results) error
+	// close is called with all the results when the scan ends
+	close(results []scan.Results, start time.Time, elapsed time.Duration) error
+}
+
+func newResultsWriter(w io.Writer, opts outputOptions) (resultsWriter, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.template != "" {
+		tmpl, err := newTemplate(opts.templateName, opts.template)
+		if err != nil {
+			return nil, err
+		}
+		return &templateWriter{w: w, tmpl: tmpl}, nil
+	}
+
+	switch opts.format {
+	case outputText, "":
+		return &textWriter{w: w}, nil
+	case outputJSON:
+		return &jsonWriter{w: w}, nil
+	case outputNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case outputCSV, outputTSV:
+		return newCSVWriter(w, opts)
+	case outputNmapXML:
+		return &nmapXMLWriter{w: w, args: opts.args}, nil
+	case outputHTML, outputMarkdown:
+		return &reportWriter{w: w, format: opts.format}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected one of %v", opts.format, outputFormats)
+	}
+}
+
+// textWriter prints the results for people once the scan ends
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) write(r scan.Results) error {
+	return nil
+}
+
+func (t *textWriter) close(results []scan.Results, start time.Time, elapsed time.Duration) error {
+	return printResults(t.w, results)
+}
+
+// jsonWriter writes a single JSON document once the scan ends
+type jsonWriter struct {
+	w io.Writer
+}
+
+func (j *jsonWriter) write(r scan.Results) error {
+	return nil
+}
+
+func (j *jsonWriter) close(results []scan.Results, start time.Time, elapsed time.Duration) error {
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(scan.Report{
+		SchemaVersion: scan.SchemaVersion,
+		Start:         start,
+		Elapsed:       elapsed,
+		Results:       results,
+	})
+}
+
+// reportWriter renders a HTML or Markdown report when the scan ends
+type reportWriter struct {
+	w      io.Writer
+	format string
+}
+
+func (rw *reportWriter) write(r scan.Results) error {
+	return nil
+}
+
+func (rw *reportWriter) close(results []scan.Results, start time.Time, elapsed time.Duration) error {
+	return writeReport(rw.w, rw.format, scan.Report{
+		SchemaVersion: scan.SchemaVersion,
+		Start:         start,
+		Elapsed:       elapsed,
+		Results:       results,
+	})
+}
+
+// ndjsonWriter writes a JSON record per line as soon as a host is scanned
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) write(r scan.Results) error {
+	return n.enc.Encode(scan.Record{SchemaVersion: scan.SchemaVersion, Result: r})
+}
+
+func (n *ndjsonWriter) close(results []scan.Results, start time.Time, elapsed time.Duration) error {
+	return nil
+}
+
+// csvWriter writes a row per port of every host as soon as the host
+// is scanned, the hosts without ports get a single row
+type csvWriter struct {
+	w          rowWriter
+	columns    []string
+	header     bool
+	headerDone bool
+}
+
+// rowWriter writes the rows of the CSV and TSV formats,
+// the methods are the ones of csv.Writer
+type rowWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+func newCSVWriter(w io.Writer, opts outputOptions) (*csvWriter, error) {
+	cols := opts.columns
+	if len(cols) == 0 {
+		cols = defaultColumns
+	}
+
+	cw := &csvWriter{columns: cols, header: !opts.noHeader}
+	if opts.format == outputTSV {
+		cw.w = &tsvWriter{w: bufio.NewWriter(w)}
+	} else {
+		cw.w = csv.NewWriter(w)
+	}
+
+	return cw, nil
+}
+
+// tsvEscape escapes the characters that can't be in a TSV field,
+// the fields are never quoted
+var tsvEscape = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvWriter writes tab separated rows with the fields escaped by tsvEscape
+type tsvWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (t *tsvWriter) Write(record []string) error {
+	for i, field := range record {
+		if i > 0 {
+			t.w.WriteByte('\t')
+		}
+		t.w.WriteString(tsvEscape.Replace(field))
+	}
+	_, err := t.w.WriteString("\n")
+	return err
+}
+
+func (t *tsvWriter) Flush() {
+	t.err = t.w.Flush()
+}
+
+func (t *tsvWriter) Error() error {
+	return t.err
+}
+
+func (c *csvWriter) writeHeader() error {
+	if !c.header || c.headerDone {
+		return nil
+	}
+	c.headerDone = true
+
+	return c.w.Write(c.columns)
+}
+
+func (c *csvWriter) row(r scan.Results, p *scan.PortState) []string {
+	row := make([]string, 0, len(c.columns))
+	for _, col := range c.columns {
+		row = append(row, columns[col](r, p))
+	}
+
+	return row
+}
+
+func (c *csvWriter) write(r scan.Results) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	if len(r.PortStates) == 0 {
+		if err := c.w.Write(c.row(r, nil)); err != nil {
+			return err
+		}
+	}
+	for i := range r.PortStates {
+		if err := c.w.Write(c.row(r, &r.PortStates[i])); err != nil {
+			return err
+		}
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) close(results []scan.Results, start time.Time, elapsed time.Duration) error {
+	// the header is written even without results
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,350 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+	"github.com/spf13/cobra"
+)
+
+//go:embed templates/report.html templates/report.md
+var reportTemplates embed.FS
+
+// riskyService is a service that should not be reachable from untrusted networks
+type riskyService struct {
+	protocol string
+	port     int
+	name     string
+	risk     string
+}
+
+var riskyServices = []riskyService{
+	{scan.ProtocolTcp, 21, "ftp", "cleartext credentials"},
+	{scan.ProtocolTcp, 23, "telnet", "cleartext credentials"},
+	{scan.ProtocolTcp, 111, "rpcbind", "exposes RPC services"},
+	{scan.ProtocolTcp, 135, "msrpc", "Windows RPC"},
+	{scan.ProtocolTcp, 139, "netbios-ssn", "Windows file sharing"},
+	{scan.ProtocolTcp, 445, "microsoft-ds", "Windows file sharing"},
+	{scan.ProtocolTcp, 512, "exec", "r-services"},
+	{scan.ProtocolTcp, 513, "login", "r-services"},
+	{scan.ProtocolTcp, 514, "shell", "r-services"},
+	{scan.ProtocolTcp, 1433, "ms-sql-s", "database"},
+	{scan.ProtocolTcp, 2375, "docker", "unauthenticated Docker API"},
+	{scan.ProtocolTcp, 3306, "mysql", "database"},
+	{scan.ProtocolTcp, 3389, "ms-wbt-server", "remote desktop"},
+	{scan.ProtocolTcp, 5432, "postgresql", "database"},
+	{scan.ProtocolTcp, 5900, "vnc", "remote desktop"},
+	{scan.ProtocolTcp, 6379, "redis", "often unauthenticated"},
+	{scan.ProtocolTcp, 9200, "elasticsearch", "often unauthenticated"},
+	{scan.ProtocolTcp, 11211, "memcache", "often unauthenticated"},
+	{scan.ProtocolTcp, 27017, "mongodb", "often unauthenticated"},
+	{scan.ProtocolUdp, 69, "tftp", "unauthenticated file transfer"},
+	{scan.ProtocolUdp, 161, "snmp", "default communities"},
+	{scan.ProtocolUdp, 1900, "ssdp", "amplification"},
+	{scan.ProtocolUdp, 11211, "memcache", "amplification"},
+}
+
+// findRisky returns the risky service on the port if there is one
+func findRisky(protocol string, port int) (riskyService, bool) {
+	for _, rs := range riskyServices {
+		if rs.protocol == protocol && rs.port == port {
+			return rs, true
+		}
+	}
+
+	return riskyService{}, false
+}
+
+// portCounts are the number of ports in each state,
+// open|filtered ports count as filtered
+type portCounts struct {
+	Open, Closed, Filtered, Risky int
+}
+
+func (c *portCounts) add(p reportPort) {
+	switch p.state {
+	case scan.StateOpen:
+		c.Open++
+	case scan.StateClosed:
+		c.Closed++
+	default:
+		c.Filtered++
+	}
+	if p.Risky {
+		c.Risky++
+	}
+}
+
+type reportPort struct {
+	Port     int
+	Protocol string
+	State    string
+	Service  string
+	Banner   string
+	// Risky is set for the open ports of risky services
+	Risky bool
+	Risk  string
+	state scan.State
+}
+
+// StateClass is the CSS class of the state
+func (p reportPort) StateClass() string {
+	return strings.ReplaceAll(p.State, "|", "-")
+}
+
+type reportHost struct {
+	Name   string
+	Status string
+	Err    string
+	Ports  []reportPort
+	Counts portCounts
+}
+
+type reportData struct {
+	Start   time.Time
+	Elapsed time.Duration
+	Hosts   []reportHost
+	Totals  struct {
+		portCounts
+		Hosts, Up, Down, NotFound int
+	}
+}
+
+func newReportData(report scan.Report) reportData {
+	data := reportData{Start: report.Start, Elapsed: report.Elapsed.Round(time.Millisecond)}
+
+	for _, r := range report.Results {
+		h := reportHost{Name: r.Host, Status: r.Status.String()}
+		if r.Address != "" {
+			h.Name = fmt.Sprintf("%s (%s)", r.Host, r.Address)
+		}
+		if r.Err != nil {
+			h.Err = r.Err.Error()
+		}
+
+		data.Totals.Hosts++
+		switch {
+		case r.NotFound:
+			h.Status = "not found"
+			data.Totals.NotFound++
+		case r.Status == scan.HostDown:
+			data.Totals.Down++
+		default:
+			data.Totals.Up++
+		}
+
+		for _, p := range r.PortStates {
+			rp := reportPort{
+				Port:     p.Port,
+				Protocol: p.Protocol,
+				State:    p.State.String(),
+				Service:  p.Service,
+				Banner:   p.Banner,
+				state:    p.State,
+			}
+			if rs, ok := findRisky(p.Protocol, p.Port); ok && p.State == scan.StateOpen {
+				rp.Risky = true
+				rp.Risk = rs.risk
+				if rp.Service == "" {
+					rp.Service = rs.name
+				}
+			}
+			h.Counts.add(rp)
+			data.Totals.portCounts.add(rp)
+			h.Ports = append(h.Ports, rp)
+		}
+
+		data.Hosts = append(data.Hosts, h)
+	}
+
+	return data
+}
+
+// mdEscape escapes the characters of text that
+// would break a Markdown table or add formatting
+var mdEscape = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "\n", " ",
+)
+
+// codeEscape escapes the text of a code span in a Markdown table,
+// backticks can't be escaped in code spans so they are replaced
+// and GFM takes \| as a pipe that doesn't end the cell
+var codeEscape = strings.NewReplacer("`", "'", "|", `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// writeReport renders the results in the report format
+func writeReport(w io.Writer, format string, report scan.Report) error {
+	data := newReportData(report)
+
+	switch format {
+	case outputHTML:
+		tmpl, err := htmltemplate.ParseFS(reportTemplates, "templates/report.html")
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, data)
+	case outputMarkdown:
+		tmpl, err := template.New("report.md").Funcs(template.FuncMap{
+			"md":   mdEscape.Replace,
+			"code": codeEscape.Replace,
+		}).ParseFS(reportTemplates, "templates/report.md")
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, data)
+	default:
+		return checkReportFormat(format)
+	}
+}
+
+// checkReportFormat checks that the format is one of the report formats
+func checkReportFormat(format string) error {
+	if format != outputHTML && format != outputMarkdown {
+		return fmt.Errorf("unknown report format %q, expected %s or %s", format, outputHTML, outputMarkdown)
+	}
+
+	return nil
+}
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report [<results file>]",
+	Short: "Render the JSON results of a scan as a HTML or Markdown report",
+	Long: `Renders the results of scan --output json or ndjson
+	read from a file or the standard input as a report
+	with a table of the ports of every host and the count of
+	open, closed and filtered ports, highlighting the risky services.
+
+	The HTML report is a single file with its styles.`,
+	Example:      "pscanner scan -T -o json > results.json\npscanner report --format markdown results.json",
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		outputFile, err := cmd.Flags().GetString("output-file")
+		if err != nil {
+			return err
+		}
+
+		// don't truncate the output file for a typo in the format
+		if err := checkReportFormat(format); err != nil {
+			return err
+		}
+
+		var in io.Reader = os.Stdin
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+
+		if outputFile == "" {
+			return reportAction(in, os.Stdout, format)
+		}
+
+		f, err := os.Create(outputFile)
+		if err != nil {
+			return err
+		}
+		err = reportAction(in, f, format)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().String("format", outputHTML, "format of the report: html or markdown")
+	reportCmd.Flags().String("output-file", "", "file to write the report to instead of the standard output")
+}
+
+func reportAction(in io.Reader, out io.Writer, format string) error {
+	report, err := readResults(in)
+	if err != nil {
+		return err
+	}
+
+	return writeReport(out, format, report)
+}
+
+// readResults reads a JSON report or a stream of NDJSON records
+func readResults(in io.Reader) (scan.Report, error) {
+	var report scan.Report
+	dec := json.NewDecoder(in)
+	for first := true; ; first = false {
+		var doc struct {
+			SchemaVersion int            `json:"schema_version"`
+			Start         time.Time      `json:"start"`
+			Elapsed       time.Duration  `json:"elapsed_ns"`
+			Results       []scan.Results `json:"results"`
+			Result        *scan.Results  `json:"result"`
+		}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) && !first {
+				break
+			}
+			return report, fmt.Errorf("reading the results: %w", err)
+		}
+		if doc.SchemaVersion < 1 || doc.SchemaVersion > scan.SchemaVersion {
+			return report, fmt.Errorf("unsupported results schema version %d", doc.SchemaVersion)
+		}
+
+		if doc.Result == nil {
+			// a single report document
+			report.Start, report.Elapsed = doc.Start, doc.Elapsed
+			report.Results = append(report.Results, doc.Results...)
+			continue
+		}
+
+		// the records have no times of the whole scan
+		r := *doc.Result
+		if report.Start.IsZero() || r.Start.Before(report.Start) {
+			report.Start = r.Start
+		}
+		if end := r.Start.Add(r.Elapsed); end.Sub(report.Start) > report.Elapsed {
+			report.Elapsed = end.Sub(report.Start)
+		}
+		report.Results = append(report.Results, r)
+	}
+	report.SchemaVersion = scan.SchemaVersion
+
+	return report, nil
+}
@@ -26,6 +26,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/Serares/pscanner/scan"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -62,17 +63,35 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pscanner.yaml)")
 
 	rootCmd.PersistentFlags().StringP("hosts-file", "f", "pscanner.hosts", "file of hosts")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "show the progress of the scan")
+	rootCmd.PersistentFlags().Bool("debug", false, "show the details of every probe")
 
 	replacer := strings.NewReplacer("-", "_")
 	viper.SetEnvKeyReplacer(replacer)
 	viper.SetEnvPrefix("PSCAN")
 
 	viper.BindPFlag("hosts-file", rootCmd.PersistentFlags().Lookup("hosts-file"))
+	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 
 	versionTemplate := `{{printf "%s, %s - version %s\n" .Name .Short .Version}}`
 	rootCmd.SetVersionTemplate(versionTemplate)
 }
 
+// newLogger returns the logger for the diagnostics
+// with the level set by the verbose and debug flags
+func newLogger() scan.Logger {
+	level := scan.LogInfo
+	if viper.GetBool("verbose") {
+		level = scan.LogVerbose
+	}
+	if viper.GetBool("debug") {
+		level = scan.LogDebug
+	}
+
+	return scan.NewLogger(os.Stderr, level)
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
@@ -22,18 +22,26 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Serares/pscanner/scan"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // scanCmd represents the scan command
 var scanCmd = &cobra.Command{
-	Use:   "scan",
-	Short: "Run port scanning on existing hosts",
+	Use:          "scan",
+	Short:        "Run port scanning on existing hosts",
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile, err := cmd.Flags().GetString("hosts-file")
 		if err != nil {
@@ -56,23 +64,209 @@ var scanCmd = &cobra.Command{
 		if !isTcp && !isUdp {
 			return fmt.Errorf("please specify a flag for network scan")
 		}
+		workers, err := cmd.Flags().GetInt("workers")
+		if err != nil {
+			return err
+		}
+		hostParallelism, err := cmd.Flags().GetInt("host-parallelism")
+		if err != nil {
+			return err
+		}
+		ipv4, err := cmd.Flags().GetBool("ipv4")
+		if err != nil {
+			return err
+		}
+		ipv6, err := cmd.Flags().GetBool("ipv6")
+		if err != nil {
+			return err
+		}
+		if ipv4 && ipv6 {
+			return fmt.Errorf("please specify only one of the IPv4 and IPv6 flags")
+		}
+		ipVersion := 0
+		if ipv4 {
+			ipVersion = 4
+		}
+		if ipv6 {
+			ipVersion = 6
+		}
+		allAddresses, err := cmd.Flags().GetBool("all-addresses")
+		if err != nil {
+			return err
+		}
+		skipDiscovery, err := cmd.Flags().GetBool("skip-discovery")
+		if err != nil {
+			return err
+		}
+		discoveryPorts, err := cmd.Flags().GetStringSlice("discovery-ports")
+		if err != nil {
+			return err
+		}
+		groups, err := cmd.Flags().GetStringSlice("group")
+		if err != nil {
+			return err
+		}
+		tags, err := cmd.Flags().GetStringSlice("tag")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		outputFile, err := cmd.Flags().GetString("output-file")
+		if err != nil {
+			return err
+		}
+		columns, err := cmd.Flags().GetStringSlice("columns")
+		if err != nil {
+			return err
+		}
+		noHeader, err := cmd.Flags().GetBool("no-header")
+		if err != nil {
+			return err
+		}
+		opts := outputOptions{
+			format:   output,
+			columns:  columns,
+			noHeader: noHeader,
+			args:     strings.Join(os.Args, " "),
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		templateFile, err := cmd.Flags().GetString("template-file")
+		if err != nil {
+			return err
+		}
+		if format != "" && templateFile != "" {
+			return fmt.Errorf("please specify only one of the format and template-file flags")
+		}
+		if (format != "" || templateFile != "") && cmd.Flags().Changed("output") {
+			return fmt.Errorf("the output flag can't be used with a template")
+		}
+		opts.template, opts.templateName = format, "format"
+		if templateFile != "" {
+			text, err := os.ReadFile(templateFile)
+			if err != nil {
+				return err
+			}
+			opts.template, opts.templateName = string(text), filepath.Base(templateFile)
+		}
+		banners, err := cmd.Flags().GetBool("banners")
+		if err != nil {
+			return err
+		}
+		bannerNudge, err := cmd.Flags().GetBool("banner-nudge")
+		if err != nil {
+			return err
+		}
+
+		probes := scan.DefaultProbes()
+		if probesFile := viper.GetString("udp-probes"); probesFile != "" {
+			if err := probes.Load(probesFile); err != nil {
+				return err
+			}
+		}
 
 		cfg := &scan.ScanCfg{
-			Tcp:   isTcp,
-			Udp:   isUdp,
-			Ports: ports,
+			Tcp:             isTcp,
+			Udp:             isUdp,
+			Ports:           ports,
+			Workers:         workers,
+			HostParallelism: hostParallelism,
+			TcpTimeout:      viper.GetDuration("tcp-timeout"),
+			UdpTimeout:      viper.GetDuration("udp-timeout"),
+			Retries:         viper.GetInt("retries"),
+			RetryBackoff:    viper.GetDuration("retry-backoff"),
+			Banners:         banners,
+			BannerTimeout:   viper.GetDuration("banner-timeout"),
+			BannerNudge:     bannerNudge,
+			UdpProbes:       probes,
+			Logger:          newLogger(),
+			MaxRate:         viper.GetFloat64("max-rate"),
+			HostRate:        viper.GetFloat64("host-rate"),
+			MaxTargets:      viper.GetInt("max-targets"),
+			IPVersion:       ipVersion,
+			AllAddresses:    allAddresses,
+			Resolver:        newResolver(),
+			SkipDiscovery:   skipDiscovery,
+			DiscoveryPorts:  discoveryPorts,
+		}
+
+		// don't truncate the output file for a typo in the options
+		if err := opts.validate(); err != nil {
+			return err
+		}
+		if err := cfg.Validate(); err != nil {
+			return err
 		}
 
-		return scanAction(os.Stdout, hostsFile, cfg)
+		// stop the scan on Ctrl-C and print what was scanned so far
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		if outputFile == "" {
+			return scanAction(ctx, os.Stdout, hostsFile, groups, tags, opts, cfg)
+		}
+
+		f, err := os.Create(outputFile)
+		if err != nil {
+			return err
+		}
+		err = scanAction(ctx, f, hostsFile, groups, tags, opts, cfg)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(scanCmd)
 
-	scanCmd.Flags().StringSliceP("ports", "p", []string{"22-443"}, "ports to scan")
+	scanCmd.Flags().StringSliceP("ports", "p", []string{"22-443"}, "ports to scan, e.g. 22,80-443,-1024,60000-,!25,ssh")
 	scanCmd.Flags().BoolP("tcp", "T", false, "use a TCP scan")
 	scanCmd.Flags().BoolP("udp", "U", false, "use a UDP scan")
+	scanCmd.Flags().StringP("output", "o", outputText, "format of the results: "+strings.Join(outputFormats, ", "))
+	scanCmd.Flags().String("output-file", "", "file to write the results to instead of the standard output")
+	scanCmd.Flags().StringSlice("columns", defaultColumns, "columns of the csv and tsv output, some of "+strings.Join(columnNames, ", "))
+	scanCmd.Flags().Bool("no-header", false, "leave out the header row of the csv and tsv output")
+	scanCmd.Flags().String("format", "", "Go template executed with the results instead of the output format, e.g. '{{range .}}{{.Host}}{{end}}'")
+	scanCmd.Flags().String("template-file", "", "file with a Go template executed with the results instead of the output format")
+	scanCmd.Flags().StringSlice("group", nil, "scan only the hosts of these groups")
+	scanCmd.Flags().StringSlice("tag", nil, "scan only the hosts with any of these tags")
+	scanCmd.Flags().BoolP("ipv4", "4", false, "scan only IPv4 addresses")
+	scanCmd.Flags().BoolP("ipv6", "6", false, "scan only IPv6 addresses")
+	scanCmd.Flags().Bool("all-addresses", false, "scan every address of a host separately")
+	scanCmd.Flags().Bool("skip-discovery", false, "scan the ports of all hosts without checking first if they are up")
+	scanCmd.Flags().StringSlice("discovery-ports", scan.DefaultDiscoveryPorts, "ports pinged to check if a host is up")
+	scanCmd.Flags().String("dns-server", "", "DNS server used to resolve the hosts instead of the system resolver")
+	scanCmd.Flags().IntP("workers", "w", scan.DefaultWorkers, "number of ports scanned in parallel on each host")
+	scanCmd.Flags().Int("host-parallelism", scan.DefaultHostParallelism, "number of hosts scanned in parallel")
+	scanCmd.Flags().Duration("tcp-timeout", scan.DefaultTcpTimeout, "time to wait for a TCP connection")
+	scanCmd.Flags().Duration("udp-timeout", scan.DefaultUdpTimeout, "time to wait for a UDP response")
+	scanCmd.Flags().Int("retries", 0, "number of retries for ports that timed out")
+	scanCmd.Flags().Duration("retry-backoff", 100*time.Millisecond, "wait before the first retry, doubled on every retry")
+	scanCmd.Flags().Bool("banners", false, "read the banners of open TCP ports")
+	scanCmd.Flags().Duration("banner-timeout", scan.DefaultBannerTimeout, "time to wait for a banner")
+	scanCmd.Flags().Bool("banner-nudge", false, "send a line break to silent ports and wait for a banner again")
+	scanCmd.Flags().String("udp-probes", "", "file with extra UDP probe payloads")
+	scanCmd.Flags().Float64("max-rate", 0, "maximum probes per second for the whole scan, 0 for no limit")
+	scanCmd.Flags().Float64("host-rate", 0, "maximum probes per second for a single host, 0 for no limit")
+	scanCmd.Flags().Int("max-targets", scan.DefaultMaxTargets, "maximum number of hosts a CIDR prefix or range of IPs can expand to")
+
+	viper.BindPFlag("tcp-timeout", scanCmd.Flags().Lookup("tcp-timeout"))
+	viper.BindPFlag("udp-timeout", scanCmd.Flags().Lookup("udp-timeout"))
+	viper.BindPFlag("retries", scanCmd.Flags().Lookup("retries"))
+	viper.BindPFlag("retry-backoff", scanCmd.Flags().Lookup("retry-backoff"))
+	viper.BindPFlag("banner-timeout", scanCmd.Flags().Lookup("banner-timeout"))
+	viper.BindPFlag("udp-probes", scanCmd.Flags().Lookup("udp-probes"))
+	viper.BindPFlag("max-rate", scanCmd.Flags().Lookup("max-rate"))
+	viper.BindPFlag("host-rate", scanCmd.Flags().Lookup("host-rate"))
+	viper.BindPFlag("max-targets", scanCmd.Flags().Lookup("max-targets"))
+	viper.BindPFlag("dns-server", scanCmd.Flags().Lookup("dns-server"))
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -83,39 +277,132 @@ func init() {
 	// is called directly, e.g.:
 }
 
-func scanAction(w io.Writer, hostsFile string, cfg *scan.ScanCfg) error {
+// newResolver returns the resolver for the hosts, using the DNS server
+// from the configuration if set and the addresses
+// of the static-hosts map from the config file before it
+func newResolver() scan.Resolver {
+	var resolver scan.Resolver = net.DefaultResolver
+	if server := viper.GetString("dns-server"); server != "" {
+		resolver = scan.NewDNSResolver(server)
+	}
+
+	if staticHosts := viper.GetStringMapStringSlice("static-hosts"); len(staticHosts) > 0 {
+		resolver = &scan.StaticResolver{Hosts: staticHosts, Fallback: resolver}
+	}
+
+	return resolver
+}
+
+// loadHosts loads the hosts list, keeping only the hosts of the groups
+// if there are groups and with any of the tags if there are tags
+func loadHosts(hostsFile string, groups, tags []string) (*scan.HostsList, error) {
 	hl := &scan.HostsList{}
 
 	if err := hl.Load(hostsFile); err != nil {
-		return err
+		return nil, err
+	}
+
+	if len(groups) > 0 {
+		var err error
+		if hl, err = hl.WithGroups(groups...); err != nil {
+			return nil, err
+		}
 	}
 
-	results := scan.Run(hl, cfg)
+	if len(tags) > 0 {
+		hl = hl.WithTags(tags...)
+	}
 
-	return printResults(w, results, cfg)
+	return hl, nil
 }
 
-func printResults(out io.Writer, results []scan.Results, cfg *scan.ScanCfg) error {
-	message := ""
-	if cfg.Tcp {
-		message += fmt.Sprint("TCP scan: \n")
+func scanAction(ctx context.Context, w io.Writer, hostsFile string, groups, tags []string, opts outputOptions, cfg *scan.ScanCfg) error {
+	rw, err := newResultsWriter(w, opts)
+	if err != nil {
+		return err
+	}
+
+	hl, err := loadHosts(hostsFile, groups, tags)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	resCh, err := scan.RunContext(ctx, hl, cfg)
+	if err != nil {
+		return err
+	}
+
+	results := []scan.Results{}
+	for r := range resCh {
+		results = append(results, r)
+		if err := rw.write(r); err != nil {
+			// stop the scan and read the rest so that it can end
+			cancel()
+			for range resCh {
+			}
+			return err
+		}
 	}
-	if cfg.Udp {
-		message += fmt.Sprint("UDP scan: \n")
+
+	elapsed := time.Since(start)
+	if err := rw.close(results, start, elapsed); err != nil {
+		return err
+	}
+
+	if cfg.Logger != nil {
+		probes, rate := scan.ProbeRate(results, elapsed)
+		cfg.Logger.Logf(scan.LogInfo, "Sent %d probes in %s (%.1f probes/s)",
+			probes, elapsed.Round(time.Millisecond), rate)
 	}
 
+	if ctx.Err() != nil {
+		return fmt.Errorf("scan interrupted: %w", ctx.Err())
+	}
+
+	return nil
+}
+
+func printResults(out io.Writer, results []scan.Results) error {
+	message := ""
+
 	for _, r := range results {
-		message += fmt.Sprintf("%s:", r.Host)
+		host := r.Host
+		if r.Address != "" {
+			host = fmt.Sprintf("%s (%s)", r.Host, r.Address)
+		}
+		message += fmt.Sprintf("%s:", host)
 
 		if r.NotFound {
 			message += fmt.Sprintf(" Host not found\n\n")
 			continue
 		}
 
+		if r.Status == scan.HostDown {
+			message += fmt.Sprintf(" Host down\n\n")
+			continue
+		}
+
 		message += fmt.Sprintln()
 
+		// port states come grouped by protocol
+		protocol := ""
 		for _, p := range r.PortStates {
-			message += fmt.Sprintf("\t%d: %s\n", p.Port, p.Open)
+			if p.Protocol != protocol {
+				protocol = p.Protocol
+				message += fmt.Sprintf("\t%s scan:\n", strings.ToUpper(protocol))
+			}
+			message += fmt.Sprintf("\t\t%d: %s", p.Port, p.State)
+			if p.Service != "" {
+				message += fmt.Sprintf(" (%s)", p.Service)
+			}
+			if p.Banner != "" {
+				message += fmt.Sprintf(" - %s", p.Banner)
+			}
+			message += fmt.Sprintln()
 		}
 
 		message += fmt.Sprintln()
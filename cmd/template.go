@@ -0,0 +1,73 @@
+/*
+Copyright © 2023 rares
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+// templateFuncs are the helpers of the output templates
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	// open returns the open ports
+	"open": func(ports []scan.PortState) []scan.PortState {
+		open := []scan.PortState{}
+		for _, p := range ports {
+			if p.State == scan.StateOpen {
+				open = append(open, p)
+			}
+		}
+		return open
+	},
+	// service returns the service that answered on the port
+	// or the one usually on it
+	"service": func(p scan.PortState) string {
+		if p.Service != "" {
+			return p.Service
+		}
+		return scan.ServiceName(p.Port, p.Protocol)
+	},
+}
+
+// newTemplate parses an output template with the helpers
+func newTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+// templateWriter executes a template with all the results when the scan ends
+type templateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (tw *templateWriter) write(r scan.Results) error {
+	return nil
+}
+
+func (tw *templateWriter) close(results []scan.Results, start time.Time, elapsed time.Duration) error {
+	return tw.tmpl.Execute(tw.w, results)
+}
@@ -0,0 +1,192 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+var ErrNoAddress = errors.New("no address for the IP version")
+
+// network returns the network to dial for the protocol,
+// restricted to the IP version of the configuration
+func (cfg *ScanCfg) network(protocol string) string {
+	switch cfg.IPVersion {
+	case 4:
+		return protocol + "4"
+	case 6:
+		return protocol + "6"
+	default:
+		return protocol
+	}
+}
+
+// lookupHost resolves a host with the resolver, IP literals are returned as they are
+// and IPv6 literals can have a zone like fe80::1%eth0,
+// only the addresses of the IP version are returned if it's set
+func lookupHost(ctx context.Context, resolver Resolver, host string, ipVersion int) ([]string, error) {
+	addrs := []string{host}
+	if _, err := netip.ParseAddr(host); err != nil {
+		if addrs, err = resolver.LookupHost(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+
+	if ipVersion == 0 {
+		return addrs, nil
+	}
+
+	filtered := []string{}
+	for _, a := range addrs {
+		ip, err := netip.ParseAddr(a)
+		if err != nil {
+			continue
+		}
+		if (ipVersion == 4) == ip.Unmap().Is4() {
+			filtered = append(filtered, a)
+		}
+	}
+
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("%w: %s has no IPv%d address", ErrNoAddress, host, ipVersion)
+	}
+
+	return filtered, nil
+}
+
+// trimBrackets removes the brackets around IPv6 literals like [::1],
+// it reports false if the host has brackets but it's not an IPv6 literal
+func trimBrackets(host string) (string, bool) {
+	if !strings.HasPrefix(host, "[") && !strings.HasSuffix(host, "]") {
+		return host, true
+	}
+
+	if !strings.HasPrefix(host, "[") || !strings.HasSuffix(host, "]") {
+		return host, false
+	}
+
+	inner := host[1 : len(host)-1]
+	ip, err := netip.ParseAddr(inner)
+	if err != nil || !ip.Is6() {
+		return host, false
+	}
+
+	return inner, true
+}
+
+// NormalizeHost checks that the host is a host name, an IP address,
+// a CIDR prefix or a range of IPv4 addresses and returns it normalized:
+// host names in lower case without the trailing dot,
+// IP addresses and prefixes in their canonical form, prefixes masked
+// like 10.0.0.0/24 for 10.0.0.5/24, and IPv6 literals without brackets
+func NormalizeHost(host string) (string, error) {
+	h, ok := trimBrackets(strings.TrimSpace(host))
+	if !ok || h == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidHost, host)
+	}
+
+	if strings.Contains(h, "://") {
+		return "", fmt.Errorf("%w: %q is a URL, not a host", ErrInvalidHost, host)
+	}
+
+	if strings.Contains(h, "/") {
+		prefix, err := netip.ParsePrefix(h)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not a valid CIDR prefix", ErrInvalidHost, host)
+		}
+		return prefix.Masked().String(), nil
+	}
+
+	if ip, err := netip.ParseAddr(h); err == nil {
+		return ip.String(), nil
+	}
+
+	if _, ok := parseOctetRanges(h); ok {
+		return h, nil
+	}
+
+	h = strings.TrimSuffix(strings.ToLower(h), ".")
+	if !isHostName(h) {
+		return "", fmt.Errorf("%w: %q is not a valid host name", ErrInvalidHost, host)
+	}
+
+	return h, nil
+}
+
+// isHostName checks the syntax of a lower case host name,
+// labels can be all numbers so names like 389.389.389.389 are valid
+// and underscores are allowed as many internal zones use them
+func isHostName(h string) bool {
+	if len(h) == 0 || len(h) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(h, ".") {
+		if len(label) == 0 || len(label) > 63 ||
+			label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// CleanHost extracts the host from a URL like https://user@host:8443/path
+// or a host and port pair like host:22 or [::1]:22,
+// other hosts are returned as they are
+func CleanHost(host string) string {
+	h := strings.TrimSpace(host)
+	if i := strings.Index(h, "://"); i >= 0 {
+		h = h[i+3:]
+	}
+	if i := strings.IndexAny(h, "/?#"); i >= 0 && !isPrefixLength(h[i:]) {
+		h = h[:i]
+	}
+	if i := strings.LastIndex(h, "@"); i >= 0 {
+		h = h[i+1:]
+	}
+
+	// a bare IPv6 address has colons but no port
+	if _, err := netip.ParseAddr(h); err == nil {
+		return h
+	}
+	if hostOnly, _, err := net.SplitHostPort(h); err == nil {
+		return hostOnly
+	}
+
+	return h
+}
+
+// isPrefixLength reports if s is the length of a CIDR prefix like /24
+func isPrefixLength(s string) bool {
+	return len(s) > 1 && s[0] == '/' && isNumeric(s[1:])
+}
+
+// CheckResolves checks that a host name resolves to at least one address,
+// IP addresses, CIDR prefixes and ranges are not resolved
+func CheckResolves(ctx context.Context, resolver Resolver, host string) error {
+	host, _ = trimBrackets(host)
+	if strings.Contains(host, "/") {
+		return nil
+	}
+	if _, ok := parseOctetRanges(host); ok {
+		return nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+
+	if _, err := lookupHost(ctx, resolver, host, 0); err != nil {
+		return fmt.Errorf("%w: %s does not resolve: %v", ErrInvalidHost, host, err)
+	}
+
+	return nil
+}
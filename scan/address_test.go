@@ -0,0 +1,241 @@
+package scan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+func TestRunIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %s", err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name        string
+		entry       string
+		ipVersion   int
+		expectHost  string
+		expectState scan.State
+		expectErr   error
+	}{
+		{"Literal", "::1", 0, "::1", scan.StateOpen, nil},
+		{"Brackets", "[::1]", 6, "::1", scan.StateOpen, nil},
+		{"Prefix", "::1/128", 6, "::1", scan.StateOpen, nil},
+		{"WrongVersion", "::1", 4, "::1", scan.StateClosed, scan.ErrNoAddress},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			if err := hl.Add(tc.entry); err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+
+			res, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{portStr}, Tcp: true, IPVersion: tc.ipVersion})
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if len(res) != 1 {
+				t.Fatalf("Expected 1 result, got %d instead\n", len(res))
+			}
+			if res[0].Host != tc.expectHost {
+				t.Errorf("Expected host %q, got %q instead\n", tc.expectHost, res[0].Host)
+			}
+
+			if tc.expectErr != nil {
+				if !res[0].NotFound || !errors.Is(res[0].Err, tc.expectErr) {
+					t.Errorf("Expected host not found with error %q, got %q instead\n", tc.expectErr, res[0].Err)
+				}
+				return
+			}
+			if len(res[0].PortStates) != 1 {
+				t.Fatalf("Expected 1 port state, got %d instead\n", len(res[0].PortStates))
+			}
+			if res[0].PortStates[0].State != tc.expectState {
+				t.Errorf("Expected state %q, got %q instead\n", tc.expectState, res[0].PortStates[0].State)
+			}
+		})
+	}
+}
+
+func TestRunAllAddresses(t *testing.T) {
+	host := "localhost"
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := net.LookupHost(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{portStr}, Tcp: true, AllAddresses: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if len(res) != len(addrs) {
+		t.Fatalf("Expected %d results, got %d instead\n", len(addrs), len(res))
+	}
+
+	for i, r := range res {
+		if r.Host != host {
+			t.Errorf("Expected host %q, got %q instead\n", host, r.Host)
+		}
+		if len(r.Addresses) != len(addrs) {
+			t.Errorf("Expected addresses %v, got %v instead\n", addrs, r.Addresses)
+		}
+		if r.Address != addrs[i] {
+			t.Errorf("Expected address %q, got %q instead\n", addrs[i], r.Address)
+		}
+		if len(r.PortStates) != 1 {
+			t.Fatalf("Expected 1 port state, got %d instead\n", len(r.PortStates))
+		}
+		expectState := scan.StateClosed
+		if r.Address == "127.0.0.1" {
+			expectState = scan.StateOpen
+		}
+		if r.PortStates[0].State != expectState {
+			t.Errorf("Expected port %s of %s to be %s\n", portStr, r.Address, expectState)
+		}
+	}
+}
+
+func TestRunAddressFallback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the port is only open on the second address
+	resolver := &scan.StaticResolver{Hosts: map[string][]string{"dual.lab": {"127.0.0.2", "127.0.0.1"}}}
+	hl := &scan.HostsList{}
+	hl.Add("dual.lab")
+
+	for _, skip := range []bool{false, true} {
+		cfg := &scan.ScanCfg{
+			Ports:          []string{portStr},
+			Tcp:            true,
+			TcpTimeout:     200 * time.Millisecond,
+			Resolver:       resolver,
+			DiscoveryPorts: []string{portStr},
+			SkipDiscovery:  skip,
+		}
+		res, err := scan.Run(hl, cfg)
+		if err != nil {
+			t.Fatalf("Expected no error, got %q instead\n", err)
+		}
+		if len(res) != 1 || len(res[0].PortStates) != 1 {
+			t.Fatalf("Expected 1 result with 1 port, got %+v instead\n", res)
+		}
+		if ps := res[0].PortStates[0]; ps.State != scan.StateOpen || ps.Attempts != 2 {
+			t.Errorf("Expected port %s open after 2 attempts, got %+v instead\n", portStr, ps)
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	testCases := []struct {
+		name       string
+		host       string
+		expectHost string
+		expectErr  error
+	}{
+		{"Name", "Foo.Example.COM.", "foo.example.com", nil},
+		{"NumericLabels", "389.389.389.389", "389.389.389.389", nil},
+		{"Underscore", "_srv.internal", "_srv.internal", nil},
+		{"IPv4", "10.0.0.1", "10.0.0.1", nil},
+		{"IPv6", "[FD00:0::1]", "fd00::1", nil},
+		{"Prefix", "FD00::/120", "fd00::/120", nil},
+		{"UnmaskedPrefix", "10.0.0.5/24", "10.0.0.0/24", nil},
+		{"Range", "10.0.1-2.*", "10.0.1-2.*", nil},
+		{"URL", "https://foo.com", "", scan.ErrInvalidHost},
+		{"URLWithPath", "http://foo.com/", "", scan.ErrInvalidHost},
+		{"HostPort", "foo.com:22", "", scan.ErrInvalidHost},
+		{"InvalidPrefix", "10.0.0.0/33", "", scan.ErrInvalidHost},
+		{"LeadingHyphen", "-foo.com", "", scan.ErrInvalidHost},
+		{"EmptyLabel", "foo..com", "", scan.ErrInvalidHost},
+		{"Empty", " ", "", scan.ErrInvalidHost},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			host, err := scan.NormalizeHost(tc.host)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error %v, got %v instead\n", tc.expectErr, err)
+			}
+			if host != tc.expectHost {
+				t.Errorf("Expected host %q, got %q instead\n", tc.expectHost, host)
+			}
+		})
+	}
+}
+
+func TestCleanHost(t *testing.T) {
+	testCases := []struct {
+		host       string
+		expectHost string
+	}{
+		{"https://user@Foo.com:8443/path?q=1", "Foo.com"},
+		{"foo.com:22", "foo.com"},
+		{"[::1]:22", "::1"},
+		{"::1", "::1"},
+		{"10.0.0.0/24", "10.0.0.0/24"},
+		{"ssh://10.0.0.1", "10.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			if host := scan.CleanHost(tc.host); host != tc.expectHost {
+				t.Errorf("Expected host %q, got %q instead\n", tc.expectHost, host)
+			}
+		})
+	}
+}
+
+func TestCheckResolves(t *testing.T) {
+	resolver := &scan.StaticResolver{Hosts: map[string][]string{"db.lab": {"10.0.0.5"}}}
+
+	testCases := []struct {
+		host      string
+		expectErr error
+	}{
+		{"db.lab", nil},
+		{"web.lab", scan.ErrInvalidHost},
+		{"10.0.0.9", nil},
+		{"10.0.0.0/24", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			err := scan.CheckResolves(context.Background(), resolver, tc.host)
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected error %v, got %v instead\n", tc.expectErr, err)
+			}
+		})
+	}
+}
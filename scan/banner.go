@@ -0,0 +1,66 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// bannerSize is the maximum number of bytes read from a banner
+const bannerSize = 256
+
+// bannerNudge is sent to services that wait for the client to talk first,
+// most line based protocols answer an empty line with an error or a greeting
+var bannerNudge = []byte("\r\n\r\n")
+
+// grabBanner reads the first bytes an open port sends
+// if nothing comes within the timeout and the nudge is enabled
+// it sends the nudge and waits for the answer
+func grabBanner(ctx context.Context, con net.Conn, cfg *ScanCfg) string {
+	defer unblockOnDone(ctx, con)()
+
+	banner := readBanner(con, cfg.BannerTimeout)
+	if banner == "" && cfg.BannerNudge && ctx.Err() == nil {
+		if _, err := con.Write(bannerNudge); err == nil {
+			banner = readBanner(con, cfg.BannerTimeout)
+		}
+	}
+
+	return banner
+}
+
+func readBanner(con net.Conn, timeout time.Duration) string {
+	buf := make([]byte, bannerSize)
+	con.SetReadDeadline(time.Now().Add(timeout))
+
+	n, _ := con.Read(buf)
+	return sanitizeBanner(buf[:n])
+}
+
+// sanitizeBanner makes a banner safe to print on a single line,
+// line breaks and tabs are escaped and the other
+// non printable bytes are written as hex escapes
+func sanitizeBanner(b []byte) string {
+	var sb strings.Builder
+
+	for _, c := range []byte(strings.TrimSpace(string(b))) {
+		switch {
+		case c == '\r':
+			sb.WriteString(`\r`)
+		case c == '\n':
+			sb.WriteString(`\n`)
+		case c == '\t':
+			sb.WriteString(`\t`)
+		case c == '\\':
+			sb.WriteString(`\\`)
+		case c < 0x20 || c > 0x7e:
+			sb.WriteString(fmt.Sprintf(`\x%02x`, c))
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
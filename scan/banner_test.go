@@ -0,0 +1,90 @@
+package scan_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+// bannerServer accepts connections and calls handle for each of them
+func bannerServer(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return portStr
+}
+
+func TestRunBanners(t *testing.T) {
+	greeting := bannerServer(t, func(conn net.Conn) {
+		conn.Write([]byte("SSH-2.0-Test\r\n\x00\xff"))
+		time.Sleep(100 * time.Millisecond)
+	})
+	// answers only after the client sends something
+	silent := bannerServer(t, func(conn net.Conn) {
+		if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+			return
+		}
+		conn.Write([]byte("HTTP/1.0 400 Bad Request\r\n"))
+	})
+
+	testCases := []struct {
+		name         string
+		port         string
+		nudge        bool
+		expectBanner string
+	}{
+		{"Greeting", greeting, false, `SSH-2.0-Test\r\n\x00\xff`},
+		{"SilentNoNudge", silent, false, ""},
+		{"SilentNudge", silent, true, "HTTP/1.0 400 Bad Request"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			hl.Add("localhost")
+
+			cfg := &scan.ScanCfg{
+				Ports:         []string{tc.port},
+				Tcp:           true,
+				Banners:       true,
+				BannerTimeout: 50 * time.Millisecond,
+				BannerNudge:   tc.nudge,
+			}
+			res, err := scan.Run(hl, cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if len(res) != 1 || len(res[0].PortStates) != 1 {
+				t.Fatalf("Expected 1 port state, got %v instead\n", res)
+			}
+			if res[0].PortStates[0].Banner != tc.expectBanner {
+				t.Errorf("Expected banner %q, got %q instead\n",
+					tc.expectBanner, res[0].PortStates[0].Banner)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package scan
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDiscoveryPorts are the ports pinged to find out if a host is up
+var DefaultDiscoveryPorts = []string{"80", "443", "22"}
+
+// HostStatus is the result of the discovery of a host
+type HostStatus int
+
+const (
+	// HostUnknown means the discovery was skipped
+	HostUnknown HostStatus = iota
+	// HostUp means the host answered one of the pings
+	HostUp
+	// HostDown means the host didn't answer any ping
+	HostDown
+)
+
+// implement the Stringer interface
+func (s HostStatus) String() string {
+	switch s {
+	case HostUp:
+		return "up"
+	case HostDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// discover pings the address on the discovery ports
+// and with an ICMP echo if the process is allowed to,
+// the host is up as soon as one of them gets an answer,
+// it returns the status and the number of pings sent
+func discover(ctx context.Context, address string, ports []int, cfg *ScanCfg) (HostStatus, int) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	up := make(chan struct{}, len(ports)+1)
+	var wg sync.WaitGroup
+	// send waits for the rate limits and counts the ping,
+	// the pings call it right before sending
+	var pings int32
+	send := func() bool {
+		if cfg.limiter.wait(ctx) != nil || cfg.hostLimiter.wait(ctx) != nil {
+			return false
+		}
+		atomic.AddInt32(&pings, 1)
+		return true
+	}
+	ping := func(f func() bool) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if f() {
+				up <- struct{}{}
+			}
+		}()
+	}
+
+	for _, p := range ports {
+		p := p
+		ping(func() bool { return tcpPing(ctx, address, p, send, cfg) })
+	}
+	ping(func() bool { return icmpPing(ctx, address, send, cfg) })
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-up:
+		cfg.logf(LogVerbose, "%s: host is up", address)
+		return HostUp, int(atomic.LoadInt32(&pings))
+	case <-done:
+		// a ping might have answered right before the others finished
+		select {
+		case <-up:
+			cfg.logf(LogVerbose, "%s: host is up", address)
+			return HostUp, int(pings)
+		default:
+		}
+		cfg.logf(LogVerbose, "%s: host is down", address)
+		return HostDown, int(pings)
+	}
+}
+
+// tcpPing connects to the port, an accepted
+// or a refused connection both mean the host is up
+func tcpPing(ctx context.Context, address string, port int, send func() bool, cfg *ScanCfg) bool {
+	if !send() {
+		return false
+	}
+
+	d := net.Dialer{Timeout: cfg.TcpTimeout}
+	con, err := d.DialContext(ctx, cfg.network(ProtocolTcp), net.JoinHostPort(address, fmt.Sprintf("%d", port)))
+	if err != nil {
+		cfg.logf(LogDebug, "%s: TCP ping on port %d: %v", address, port, err)
+		return isRefused(err)
+	}
+
+	con.Close()
+	return true
+}
+
+var icmpSeq uint32
+
+// icmpPing sends an ICMP echo request and waits for the reply,
+// it needs the privileges to open raw sockets and reports false without them
+// before calling send
+func icmpPing(ctx context.Context, address string, send func() bool, cfg *ScanCfg) bool {
+	ip, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+
+	network, listenAddr, echoType, replyType := "ip4:icmp", "0.0.0.0", byte(8), byte(0)
+	if ip.Is6() {
+		network, listenAddr, echoType, replyType = "ip6:ipv6-icmp", "::", 128, 129
+	}
+
+	con, err := net.ListenPacket(network, listenAddr)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			cfg.logf(LogDebug, "%s: ICMP ping needs privileges, skipping", address)
+		} else {
+			cfg.logf(LogDebug, "%s: ICMP ping: %v", address, err)
+		}
+		return false
+	}
+	defer con.Close()
+	defer unblockOnDone(ctx, con)()
+
+	id := uint16(os.Getpid())
+	seq := uint16(atomic.AddUint32(&icmpSeq, 1))
+	msg := []byte{echoType, 0, 0, 0, 0, 0, 0, 0, 'p', 's', 'c', 'a', 'n'}
+	binary.BigEndian.PutUint16(msg[4:], id)
+	binary.BigEndian.PutUint16(msg[6:], seq)
+	if ip.Is4() {
+		// the kernel computes the checksum for ICMPv6
+		binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+	}
+
+	if !send() {
+		return false
+	}
+	dst := &net.IPAddr{IP: ip.AsSlice(), Zone: ip.Zone()}
+	if _, err := con.WriteTo(msg, dst); err != nil {
+		cfg.logf(LogDebug, "%s: ICMP ping: %v", address, err)
+		return false
+	}
+
+	// the raw socket gets all the ICMP packets, wait for the matching reply
+	con.SetReadDeadline(time.Now().Add(cfg.TcpTimeout))
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := con.ReadFrom(buf)
+		if err != nil {
+			return false
+		}
+
+		fromIP, ok := from.(*net.IPAddr)
+		if !ok || !fromIP.IP.Equal(dst.IP) || n < 8 {
+			continue
+		}
+		if buf[0] == replyType &&
+			binary.BigEndian.Uint16(buf[4:]) == id &&
+			binary.BigEndian.Uint16(buf[6:]) == seq {
+			return true
+		}
+	}
+}
+
+// icmpChecksum is the internet checksum of RFC 1071
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return ^uint16(sum)
+}
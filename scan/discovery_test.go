@@ -0,0 +1,100 @@
+package scan_test
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+func TestHostStatusString(t *testing.T) {
+	testCases := []struct {
+		status scan.HostStatus
+		expect string
+	}{
+		{scan.HostUnknown, "unknown"},
+		{scan.HostUp, "up"},
+		{scan.HostDown, "down"},
+	}
+	for _, tc := range testCases {
+		if tc.status.String() != tc.expect {
+			t.Errorf("Expected %q, got %q instead\n", tc.expect, tc.status.String())
+		}
+	}
+}
+
+func TestRunDiscovery(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		host         string
+		skip         bool
+		expectStatus scan.HostStatus
+		expectPorts  int
+	}{
+		{"Up", "127.0.0.1", false, scan.HostUp, 1},
+		// TEST-NET-1 addresses are never assigned so nothing answers
+		{"Down", "192.0.2.1", false, scan.HostDown, 0},
+		{"SkipDiscovery", "192.0.2.1", true, scan.HostUnknown, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.expectStatus == scan.HostDown && !isSilent(tc.host, portStr) {
+				t.Skipf("the network answers for %s", tc.host)
+			}
+
+			hl := &scan.HostsList{}
+			hl.Add(tc.host)
+
+			cfg := &scan.ScanCfg{
+				Ports:          []string{portStr},
+				Tcp:            true,
+				TcpTimeout:     200 * time.Millisecond,
+				DiscoveryPorts: []string{portStr},
+				SkipDiscovery:  tc.skip,
+			}
+			res, err := scan.Run(hl, cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if len(res) != 1 {
+				t.Fatalf("Expected 1 result, got %d instead\n", len(res))
+			}
+			if res[0].Status != tc.expectStatus {
+				t.Errorf("Expected status %q, got %q instead\n", tc.expectStatus, res[0].Status)
+			}
+			if tc.skip != (res[0].Pings == 0) {
+				t.Errorf("Expected pings only without skipping the discovery, got %d\n", res[0].Pings)
+			}
+			if len(res[0].PortStates) != tc.expectPorts {
+				t.Errorf("Expected %d port states, got %d instead\n", tc.expectPorts, len(res[0].PortStates))
+			}
+		})
+	}
+}
+
+// isSilent checks that nothing answers for the host,
+// some sandboxes refuse all the outgoing connections
+func isSilent(host, port string) bool {
+	con, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), 200*time.Millisecond)
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, syscall.EHOSTUNREACH) ||
+			errors.Is(err, syscall.ENETUNREACH)
+	}
+	con.Close()
+	return false
+}
@@ -0,0 +1,71 @@
+package scan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// lockFile returns the path of the lock file of the hosts file,
+// the hosts file itself can't be locked since Save replaces it
+func lockFile(hostsFile string) string {
+	return hostsFile + ".lock"
+}
+
+// Update loads the hosts file holding an exclusive lock on it,
+// calls update with the list and saves it if update returns no error.
+// Concurrent updates of the same file wait for each other
+// so none of the changes is lost.
+func Update(hostsFile string, update func(hl *HostsList) error) error {
+	unlock, err := lock(lockFile(hostsFile))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hl := &HostsList{}
+	if err := hl.Load(hostsFile); err != nil {
+		return err
+	}
+
+	if err := update(hl); err != nil {
+		return err
+	}
+
+	return hl.Save(hostsFile)
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it
+// to the file, so the file is never left half written.
+// The permissions of an existing file are kept.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	if fi, err := os.Stat(name); err == nil {
+		perm = fi.Mode().Perm()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// removing the file fails harmlessly after the rename
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
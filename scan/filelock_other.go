@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package scan
+
+// lock doesn't lock on the systems without flock,
+// the atomic saves still keep the hosts file whole
+func lock(name string) (func(), error) {
+	return func() {}, nil
+}
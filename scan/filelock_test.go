@@ -0,0 +1,98 @@
+package scan_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+func TestUpdateConcurrent(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+
+	const updates = 20
+	var wg sync.WaitGroup
+	errCh := make(chan error, updates)
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errCh <- scan.Update(hostsFile, func(hl *scan.HostsList) error {
+				return hl.Add(fmt.Sprintf("host%d", i))
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Fatalf("Expected no error, got %q instead\n", err)
+		}
+	}
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(hostsFile); err != nil {
+		t.Fatal(err)
+	}
+	if len(hl.Hosts) != updates {
+		t.Errorf("Expected %d hosts, got %d instead\n", updates, len(hl.Hosts))
+	}
+}
+
+func TestUpdateError(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hostsFile, []byte("host1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the list is not saved when the update fails
+	err := scan.Update(hostsFile, func(hl *scan.HostsList) error {
+		hl.Add("host2")
+		return hl.Add("host1")
+	})
+	if err == nil {
+		t.Fatalf("Expected an error, got nil instead\n")
+	}
+
+	content, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "host1\n" {
+		t.Errorf("Expected hosts file %q, got %q instead\n", "host1\n", content)
+	}
+}
+
+func TestSaveKeepsPermissions(t *testing.T) {
+	dir := t.TempDir()
+	hostsFile := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(hostsFile, []byte("host1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("host2")
+	if err := hl.Save(hostsFile); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	fi, err := os.Stat(hostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions %v, got %v instead\n", os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the hosts file, got %d files instead\n", len(entries))
+	}
+}
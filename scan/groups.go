@@ -0,0 +1,216 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrGroupExists    = errors.New("group already exists")
+	ErrGroupNotExists = errors.New("group does not exist")
+	ErrInvalidGroup   = errors.New("invalid group")
+	ErrGroupCycle     = errors.New("group would contain itself")
+)
+
+// groupPrefix marks the members of a group that are other groups
+const groupPrefix = "@"
+
+// CreateGroup adds an empty group to the list
+func (h *HostsList) CreateGroup(name string) error {
+	if name == "" || strings.HasPrefix(name, groupPrefix) || strings.ContainsAny(name, " \t,") {
+		return fmt.Errorf("%w: %q", ErrInvalidGroup, name)
+	}
+	if _, ok := h.Groups[name]; ok {
+		return fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
+
+	if h.Groups == nil {
+		h.Groups = map[string][]string{}
+	}
+	h.Groups[name] = []string{}
+
+	return nil
+}
+
+// DeleteGroup removes a group from the list and from the groups nesting it
+func (h *HostsList) DeleteGroup(name string) error {
+	if _, ok := h.Groups[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrGroupNotExists, name)
+	}
+	delete(h.Groups, name)
+
+	for g := range h.Groups {
+		h.removeMember(g, groupPrefix+name)
+	}
+
+	return nil
+}
+
+// AddToGroup adds a member to a group, a member is a host of the list
+// or another group with the name prefixed by @, like @dmz
+func (h *HostsList) AddToGroup(group, member string) error {
+	members, ok := h.Groups[group]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrGroupNotExists, group)
+	}
+
+	if nested, ok := strings.CutPrefix(member, groupPrefix); ok {
+		if _, ok := h.Groups[nested]; !ok {
+			return fmt.Errorf("%w: %s", ErrGroupNotExists, nested)
+		}
+		if nested == group || h.nests(nested, group) {
+			return fmt.Errorf("%w: %s in %s", ErrGroupCycle, nested, group)
+		}
+	} else {
+		member = hostKey(member)
+		if found, _ := h.search(member); !found {
+			return fmt.Errorf("%w, %s", ErrNotExists, member)
+		}
+	}
+
+	for _, m := range members {
+		if m == member {
+			return fmt.Errorf("%w: %s in group %s", ErrExists, member, group)
+		}
+	}
+	h.Groups[group] = append(members, member)
+
+	return nil
+}
+
+// RemoveFromGroup removes a member from a group
+func (h *HostsList) RemoveFromGroup(group, member string) error {
+	if _, ok := h.Groups[group]; !ok {
+		return fmt.Errorf("%w: %s", ErrGroupNotExists, group)
+	}
+	if !strings.HasPrefix(member, groupPrefix) {
+		member = hostKey(member)
+	}
+
+	if !h.removeMember(group, member) {
+		return fmt.Errorf("%w, %s in group %s", ErrNotExists, member, group)
+	}
+
+	return nil
+}
+
+func (h *HostsList) removeMember(group, member string) bool {
+	members := h.Groups[group]
+	for i, m := range members {
+		if m == member {
+			h.Groups[group] = append(members[:i], members[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// GroupNames returns the names of the groups sorted
+func (h *HostsList) GroupNames() []string {
+	names := make([]string, 0, len(h.Groups))
+	for g := range h.Groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GroupHosts returns the hosts of a group and of the groups
+// nested in it, in the order they were added without duplicates
+func (h *HostsList) GroupHosts(group string) ([]string, error) {
+	hosts := []string{}
+	seen := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var expand func(g string) error
+	expand = func(g string) error {
+		members, ok := h.Groups[g]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrGroupNotExists, g)
+		}
+		// a hosts file edited by hand can have cycles
+		if visiting[g] {
+			return fmt.Errorf("%w: %s", ErrGroupCycle, g)
+		}
+		visiting[g] = true
+		defer delete(visiting, g)
+
+		for _, m := range members {
+			if nested, ok := strings.CutPrefix(m, groupPrefix); ok {
+				if err := expand(nested); err != nil {
+					return err
+				}
+				continue
+			}
+			if !seen[m] {
+				seen[m] = true
+				hosts = append(hosts, m)
+			}
+		}
+
+		return nil
+	}
+
+	if err := expand(group); err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
+// WithGroups returns the list of the hosts of any of the groups
+func (h *HostsList) WithGroups(groups ...string) (*HostsList, error) {
+	grouped := &HostsList{Format: h.Format}
+	seen := map[string]bool{}
+	for _, g := range groups {
+		hosts, err := h.GroupHosts(g)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, host := range hosts {
+			if seen[host] {
+				continue
+			}
+			seen[host] = true
+			grouped.Hosts = append(grouped.Hosts, host)
+			if info, ok := h.Info[host]; ok {
+				if grouped.Info == nil {
+					grouped.Info = map[string]HostInfo{}
+				}
+				grouped.Info[host] = info
+			}
+		}
+	}
+
+	return grouped, nil
+}
+
+// nests reports if the group contains the other group at any depth
+func (h *HostsList) nests(group, other string) bool {
+	visited := map[string]bool{}
+
+	var walk func(g string) bool
+	walk = func(g string) bool {
+		if visited[g] {
+			return false
+		}
+		visited[g] = true
+
+		for _, m := range h.Groups[g] {
+			if nested, ok := strings.CutPrefix(m, groupPrefix); ok {
+				if nested == other || walk(nested) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	return walk(group)
+}
@@ -0,0 +1,159 @@
+package scan_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+// groupsList returns a list where dmz has host1 and the web group
+// and web has host2 and host3
+func groupsList(t *testing.T) *scan.HostsList {
+	t.Helper()
+	hl := &scan.HostsList{}
+	for _, h := range []string{"host1", "host2", "host3", "host4"} {
+		if err := hl.Add(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, g := range []string{"dmz", "web"} {
+		if err := hl.CreateGroup(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, m := range [][2]string{{"web", "host2"}, {"web", "host3"}, {"dmz", "host1"}, {"dmz", "@web"}} {
+		if err := hl.AddToGroup(m[0], m[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return hl
+}
+
+func TestCreateGroup(t *testing.T) {
+	testCases := []struct {
+		name      string
+		group     string
+		expectErr error
+	}{
+		{"New", "db", nil},
+		{"Existing", "dmz", scan.ErrGroupExists},
+		{"Empty", "", scan.ErrInvalidGroup},
+		{"Prefixed", "@db", scan.ErrInvalidGroup},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := groupsList(t)
+			err := hl.CreateGroup(tc.group)
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected error %v, got %v instead\n", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestAddToGroup(t *testing.T) {
+	testCases := []struct {
+		name      string
+		group     string
+		member    string
+		expectErr error
+	}{
+		{"Host", "web", "host4", nil},
+		{"Existing", "web", "host2", scan.ErrExists},
+		{"UnknownHost", "web", "host5", scan.ErrNotExists},
+		{"UnknownGroup", "db", "host1", scan.ErrGroupNotExists},
+		{"UnknownNested", "web", "@db", scan.ErrGroupNotExists},
+		{"Self", "web", "@web", scan.ErrGroupCycle},
+		{"Cycle", "web", "@dmz", scan.ErrGroupCycle},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := groupsList(t)
+			err := hl.AddToGroup(tc.group, tc.member)
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected error %v, got %v instead\n", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestGroupHosts(t *testing.T) {
+	hl := groupsList(t)
+
+	hosts, err := hl.GroupHosts("dmz")
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	expected := []string{"host1", "host2", "host3"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected hosts %q, got %q instead\n", expected, hosts)
+	}
+
+	// removed hosts and groups leave the groups
+	if err := hl.Remove("host2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hl.RemoveFromGroup("dmz", "host1"); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = hl.GroupHosts("dmz")
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	expected = []string{"host3"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("Expected hosts %q, got %q instead\n", expected, hosts)
+	}
+
+	if err := hl.DeleteGroup("web"); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = hl.GroupHosts("dmz")
+	if err != nil || len(hosts) != 0 {
+		t.Errorf("Expected an empty group, got %q %v instead\n", hosts, err)
+	}
+}
+
+func TestGroupHostsCycle(t *testing.T) {
+	// a hosts file edited by hand
+	hl := &scan.HostsList{Groups: map[string][]string{
+		"a": {"host1", "@b"},
+		"b": {"@a"},
+	}}
+
+	if _, err := hl.GroupHosts("a"); !errors.Is(err, scan.ErrGroupCycle) {
+		t.Errorf("Expected error %q, got %v instead\n", scan.ErrGroupCycle, err)
+	}
+}
+
+func TestSaveLoadGroups(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	hl1 := groupsList(t)
+	hl1.CreateGroup("empty")
+	if err := hl1.Save(hostsFile); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	hl2 := &scan.HostsList{}
+	if err := hl2.Load(hostsFile); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if !reflect.DeepEqual(hl2.Groups, hl1.Groups) {
+		t.Errorf("Expected groups %v, got %v instead\n", hl1.Groups, hl2.Groups)
+	}
+
+	grouped, err := hl2.WithGroups("web", "dmz")
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	expected := []string{"host2", "host3", "host1"}
+	if !reflect.DeepEqual(grouped.Hosts, expected) {
+		t.Errorf("Expected hosts %q, got %q instead\n", expected, grouped.Hosts)
+	}
+}
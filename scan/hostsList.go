@@ -2,21 +2,86 @@ package scan
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 var (
-	ErrExists    = errors.New("host already in the list")
-	ErrNotExists = errors.New("host not in the list")
+	ErrExists      = errors.New("host already in the list")
+	ErrNotExists   = errors.New("host not in the list")
+	ErrInvalidHost = errors.New("invalid host")
+)
+
+// HostsFormat is the format of a hosts file
+type HostsFormat int
+
+const (
+	// FormatText is a host per line, empty lines and lines starting with # are ignored
+	FormatText HostsFormat = iota
+	// FormatYAML is a YAML document with a list of hosts and their metadata
+	FormatYAML
+	// FormatJSON is the same document as FormatYAML in JSON
+	FormatJSON
 )
 
+// HostInfo is the metadata of a host of the list
+type HostInfo struct {
+	Tags  []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Owner string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Notes string   `json:"notes,omitempty" yaml:"notes,omitempty"`
+	// Ports is a port specification like for ParsePorts,
+	// it replaces the ports of the scan for the host
+	Ports string `json:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+func (i HostInfo) isEmpty() bool {
+	return len(i.Tags) == 0 && i.Owner == "" && i.Notes == "" && i.Ports == ""
+}
+
+// HasTag reports if the host has any of the tags
+func (i HostInfo) HasTag(tags ...string) bool {
+	for _, t := range tags {
+		for _, it := range i.Tags {
+			if it == t {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hostEntry is a host of a YAML or JSON hosts file
+type hostEntry struct {
+	Host     string `json:"host" yaml:"host"`
+	HostInfo `yaml:",inline"`
+}
+
+type hostsDocument struct {
+	Hosts  []hostEntry         `json:"hosts" yaml:"hosts"`
+	Groups map[string][]string `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
 // a list o hosts to run port scan
 
 type HostsList struct {
 	Hosts []string
+	// Info has the metadata of the hosts that have any
+	Info map[string]HostInfo
+	// Groups maps the names of the groups to their members,
+	// hosts of the list or other groups prefixed by @
+	Groups map[string][]string
+	// Format is the format of the hosts file, set by Load
+	// and used by Save
+	Format HostsFormat
 }
 
 func (h *HostsList) search(host string) (bool, int) {
@@ -31,7 +96,14 @@ func (h *HostsList) search(host string) (bool, int) {
 	return false, -1
 }
 
+// Add adds a host to the list normalized by NormalizeHost,
+// it returns ErrInvalidHost if the host is not valid
 func (h *HostsList) Add(host string) error {
+	host, err := NormalizeHost(host)
+	if err != nil {
+		return err
+	}
+
 	if found, _ := h.search(host); found {
 		return fmt.Errorf("%w: %s", ErrExists, host)
 	}
@@ -40,16 +112,80 @@ func (h *HostsList) Add(host string) error {
 	return nil
 }
 
+// hostKey returns the host as Add stores it, hosts that
+// are not valid are kept as they are since a hosts file can have them
+func hostKey(host string) string {
+	if normalized, err := NormalizeHost(host); err == nil {
+		return normalized
+	}
+
+	return host
+}
+
 func (h *HostsList) Remove(host string) error {
+	host = hostKey(host)
 	if found, i := h.search(host); found {
 		h.Hosts = append(h.Hosts[:i], h.Hosts[i+1:]...)
+		delete(h.Info, host)
+		for g := range h.Groups {
+			h.removeMember(g, host)
+		}
 		return nil
 	}
 	return fmt.Errorf("%w, %s", ErrNotExists, host)
 }
 
+// SetInfo sets the metadata of a host of the list,
+// the port specification is checked with ParsePorts
+func (h *HostsList) SetInfo(host string, info HostInfo) error {
+	host = hostKey(host)
+	if found, _ := h.search(host); !found {
+		return fmt.Errorf("%w, %s", ErrNotExists, host)
+	}
+
+	if info.Ports != "" {
+		if _, err := ParsePorts([]string{info.Ports}); err != nil {
+			return fmt.Errorf("%s: %w", host, err)
+		}
+	}
+
+	if info.isEmpty() {
+		delete(h.Info, host)
+		return nil
+	}
+	if h.Info == nil {
+		h.Info = map[string]HostInfo{}
+	}
+	h.Info[host] = info
+
+	return nil
+}
+
+// WithTags returns the list of the hosts that have any of the tags
+func (h *HostsList) WithTags(tags ...string) *HostsList {
+	tagged := &HostsList{Format: h.Format}
+	for _, host := range h.Hosts {
+		info, ok := h.Info[host]
+		if !ok || !info.HasTag(tags...) {
+			continue
+		}
+		tagged.Hosts = append(tagged.Hosts, host)
+		if tagged.Info == nil {
+			tagged.Info = map[string]HostInfo{}
+		}
+		tagged.Info[host] = info
+	}
+
+	return tagged
+}
+
+// Load reads the hosts from a file, the format is detected from the
+// extension of the file (.yaml, .yml or .json) or else from its content,
+// the hosts are normalized like Add does and the duplicates dropped
 func (h *HostsList) Load(hostsfile string) error {
-	f, err := os.Open(hostsfile)
+	h.Format = formatFromExt(hostsfile)
+
+	data, err := os.ReadFile(hostsfile)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil
@@ -57,22 +193,153 @@ func (h *HostsList) Load(hostsfile string) error {
 		return err
 	}
 
-	defer f.Close()
+	if h.Format == FormatText {
+		h.Format = detectFormat(data)
+	}
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		h.Hosts = append(h.Hosts, scanner.Text())
+	loaded := map[string]bool{}
+	switch h.Format {
+	case FormatYAML, FormatJSON:
+		var doc hostsDocument
+		if h.Format == FormatJSON {
+			err = json.Unmarshal(data, &doc)
+		} else {
+			err = yaml.Unmarshal(data, &doc)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", hostsfile, err)
+		}
+
+		for _, e := range doc.Hosts {
+			host := strings.TrimSpace(e.Host)
+			if host == "" {
+				return fmt.Errorf("%s: %w: host without a name", hostsfile, ErrInvalidHost)
+			}
+			if !h.appendLoaded(loaded, host) {
+				continue
+			}
+			host = hostKey(host)
+			if !e.HostInfo.isEmpty() {
+				if h.Info == nil {
+					h.Info = map[string]HostInfo{}
+				}
+				h.Info[host] = e.HostInfo
+			}
+		}
+		for g, members := range doc.Groups {
+			if h.Groups == nil {
+				h.Groups = map[string][]string{}
+			}
+			normalized := []string{}
+			for _, m := range members {
+				if !strings.HasPrefix(m, groupPrefix) {
+					m = hostKey(m)
+				}
+				normalized = append(normalized, m)
+			}
+			h.Groups[g] = normalized
+		}
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			h.appendLoaded(loaded, line)
+		}
+		return scanner.Err()
 	}
 
 	return nil
 }
 
+// appendLoaded appends a host read from a file normalized by hostKey,
+// it reports false if the host was already loaded
+func (h *HostsList) appendLoaded(loaded map[string]bool, host string) bool {
+	host = hostKey(host)
+	if loaded[host] {
+		return false
+	}
+
+	loaded[host] = true
+	h.Hosts = append(h.Hosts, host)
+	return true
+}
+
+// Save writes the hosts to a file in the format of the list,
+// a text list is saved as YAML if any host has metadata or there are groups
 func (h *HostsList) Save(hostsFile string) error {
-	output := ""
+	if h.Format == FormatText && (len(h.Info) > 0 || len(h.Groups) > 0) {
+		h.Format = FormatYAML
+	}
+
+	if h.Format == FormatText {
+		output := ""
 
+		for _, host := range h.Hosts {
+			output += fmt.Sprintln(host)
+		}
+
+		return writeFileAtomic(hostsFile, []byte(output), 0644)
+	}
+
+	doc := hostsDocument{Hosts: make([]hostEntry, 0, len(h.Hosts)), Groups: h.Groups}
 	for _, host := range h.Hosts {
-		output += fmt.Sprintln(host)
+		doc.Hosts = append(doc.Hosts, hostEntry{Host: host, HostInfo: h.Info[host]})
+	}
+
+	var buf bytes.Buffer
+	if h.Format == FormatJSON {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	} else {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(hostsFile, buf.Bytes(), 0644)
+}
+
+func formatFromExt(hostsFile string) HostsFormat {
+	switch strings.ToLower(filepath.Ext(hostsFile)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatText
+	}
+}
+
+// detectFormat finds the format of a file without a known extension
+// from its first line that is not empty or a comment
+func detectFormat(data []byte) HostsFormat {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "{"):
+			return FormatJSON
+		case line == "---", strings.HasPrefix(line, "hosts:"):
+			return FormatYAML
+		default:
+			return FormatText
+		}
 	}
 
-	return os.WriteFile(hostsFile, []byte(output), 0644)
+	return FormatText
 }
@@ -3,6 +3,8 @@ package scan_test
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/Serares/pscanner/scan"
@@ -20,6 +22,8 @@ func TestAdd(t *testing.T) {
 	}{
 		{"AddNew", "host2", 2, nil},
 		{"AddExisting", "host1", 1, scan.ErrExists},
+		{"AddExistingNormalized", "HOST1.", 1, scan.ErrExists},
+		{"AddInvalid", "https://host2", 1, scan.ErrInvalidHost},
 	}
 
 	for _, tc := range testCases {
@@ -56,6 +60,40 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestAddIPv6Brackets(t *testing.T) {
+	testCases := []struct {
+		name       string
+		host       string
+		expectHost string
+		expectErr  error
+	}{
+		{"Brackets", "[::1]", "::1", nil},
+		{"Zone", "fe80::1%eth0", "fe80::1%eth0", nil},
+		{"Unclosed", "[::1", "", scan.ErrInvalidHost},
+		{"NotIPv6", "[host1]", "", scan.ErrInvalidHost},
+		{"IPv4", "[127.0.0.1]", "", scan.ErrInvalidHost},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			err := hl.Add(tc.host)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected error %q, got %q instead\n", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if hl.Hosts[0] != tc.expectHost {
+				t.Errorf("Expected host %q, got %q instead\n", tc.expectHost, hl.Hosts[0])
+			}
+		})
+	}
+}
+
 func TestRemove(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -134,3 +172,135 @@ func TestLoadNoFile(t *testing.T) {
 		t.Errorf("Expected no error, got %q instead\n", err)
 	}
 }
+
+func TestLoadFormats(t *testing.T) {
+	prod := scan.HostInfo{Tags: []string{"prod", "web"}, Owner: "ops", Ports: "80,443"}
+
+	testCases := []struct {
+		name         string
+		file         string
+		content      string
+		expectFormat scan.HostsFormat
+		expectHosts  []string
+		expectInfo   map[string]scan.HostInfo
+	}{
+		{"Text", "hosts", "# web servers\n  host1  \n\nhost2\n",
+			scan.FormatText, []string{"host1", "host2"}, nil},
+		{"YAML", "hosts.yaml", "hosts:\n  - host: host1\n    tags: [prod, web]\n    owner: ops\n    ports: 80,443\n  - host: host2\n",
+			scan.FormatYAML, []string{"host1", "host2"}, map[string]scan.HostInfo{"host1": prod}},
+		{"YAMLDetected", "hosts", "# web servers\nhosts:\n  - host: host1\n",
+			scan.FormatYAML, []string{"host1"}, nil},
+		{"JSONDetected", "hosts", `{"hosts": [{"host": "host1", "tags": ["prod", "web"], "owner": "ops", "ports": "80,443"}]}`,
+			scan.FormatJSON, []string{"host1"}, map[string]scan.HostInfo{"host1": prod}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hostsFile := filepath.Join(t.TempDir(), tc.file)
+			if err := os.WriteFile(hostsFile, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			hl := &scan.HostsList{}
+			if err := hl.Load(hostsFile); err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if hl.Format != tc.expectFormat {
+				t.Errorf("Expected format %d, got %d instead\n", tc.expectFormat, hl.Format)
+			}
+			if !reflect.DeepEqual(hl.Hosts, tc.expectHosts) {
+				t.Errorf("Expected hosts %q, got %q instead\n", tc.expectHosts, hl.Hosts)
+			}
+			if !reflect.DeepEqual(hl.Info, tc.expectInfo) {
+				t.Errorf("Expected info %v, got %v instead\n", tc.expectInfo, hl.Info)
+			}
+		})
+	}
+}
+
+func TestLoadNormalize(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hostsFile, []byte("Foo.com\nbar.com.\nfoo.com\nweb/01\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(hostsFile); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	// invalid entries of a file edited by hand are kept as they are
+	expected := []string{"foo.com", "bar.com", "web/01"}
+	if !reflect.DeepEqual(hl.Hosts, expected) {
+		t.Errorf("Expected hosts %q, got %q instead\n", expected, hl.Hosts)
+	}
+
+	if err := hl.Add("foo.com"); !errors.Is(err, scan.ErrExists) {
+		t.Errorf("Expected error %q, got %q instead\n", scan.ErrExists, err)
+	}
+	for _, host := range []string{"Foo.com", "bar.com.", "web/01"} {
+		if err := hl.Remove(host); err != nil {
+			t.Errorf("Expected no error removing %q, got %q instead\n", host, err)
+		}
+	}
+	if len(hl.Hosts) != 0 {
+		t.Errorf("Expected no hosts, got %q instead\n", hl.Hosts)
+	}
+}
+
+func TestSaveLoadInfo(t *testing.T) {
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	info := scan.HostInfo{Tags: []string{"prod"}, Notes: "primary", Ports: "22,80-90"}
+
+	hl1 := &scan.HostsList{}
+	hl1.Add("host1")
+	hl1.Add("host2")
+	if err := hl1.SetInfo("host2", info); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if err := hl1.Save(hostsFile); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	// the metadata can't be saved as text
+	hl2 := &scan.HostsList{}
+	if err := hl2.Load(hostsFile); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if hl2.Format != scan.FormatYAML {
+		t.Errorf("Expected format %d, got %d instead\n", scan.FormatYAML, hl2.Format)
+	}
+	if !reflect.DeepEqual(hl2.Hosts, hl1.Hosts) {
+		t.Errorf("Expected hosts %q, got %q instead\n", hl1.Hosts, hl2.Hosts)
+	}
+	if !reflect.DeepEqual(hl2.Info["host2"], info) {
+		t.Errorf("Expected info %v, got %v instead\n", info, hl2.Info["host2"])
+	}
+}
+
+func TestSetInfo(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("host1")
+
+	if err := hl.SetInfo("host2", scan.HostInfo{}); !errors.Is(err, scan.ErrNotExists) {
+		t.Errorf("Expected error %q, got %q instead\n", scan.ErrNotExists, err)
+	}
+	if err := hl.SetInfo("host1", scan.HostInfo{Ports: "70000"}); err == nil {
+		t.Errorf("Expected error for invalid ports, got nil instead\n")
+	}
+}
+
+func TestWithTags(t *testing.T) {
+	hl := &scan.HostsList{}
+	for _, h := range []string{"host1", "host2", "host3"} {
+		hl.Add(h)
+	}
+	hl.SetInfo("host1", scan.HostInfo{Tags: []string{"prod"}})
+	hl.SetInfo("host2", scan.HostInfo{Tags: []string{"dev"}})
+	hl.SetInfo("host3", scan.HostInfo{Tags: []string{"dev", "prod"}})
+
+	tagged := hl.WithTags("prod")
+	expected := []string{"host1", "host3"}
+	if !reflect.DeepEqual(tagged.Hosts, expected) {
+		t.Errorf("Expected hosts %q, got %q instead\n", expected, tagged.Hosts)
+	}
+}
@@ -0,0 +1,554 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown inventory format")
+	ErrInvalidFormat = errors.New("invalid inventory")
+)
+
+// InventoryFormat is a format of the files hosts are imported from
+type InventoryFormat string
+
+const (
+	// InventoryAuto detects the format from the file name and content
+	InventoryAuto InventoryFormat = ""
+	// InventoryList is a list of hosts separated by spaces, commas or new lines
+	InventoryList InventoryFormat = "list"
+	// InventoryCSV takes the host, hostname, ip or address column, or the first one
+	InventoryCSV InventoryFormat = "csv"
+	// InventoryNmapXML takes the addresses of the hosts up in the output of nmap -oX
+	InventoryNmapXML InventoryFormat = "nmap-xml"
+	// InventoryNmapGrep takes the addresses of the hosts up in the output of nmap -oG
+	InventoryNmapGrep InventoryFormat = "nmap-grep"
+	// InventoryEtcHosts takes the addresses of a /etc/hosts file
+	// without the loopback and multicast ones
+	InventoryEtcHosts InventoryFormat = "etc-hosts"
+	// InventorySSHConfig takes the HostName of the Host entries of a ssh config,
+	// or the names of the entries that are not patterns
+	InventorySSHConfig InventoryFormat = "ssh-config"
+	// InventoryAnsibleINI takes the hosts of an Ansible INI inventory
+	InventoryAnsibleINI InventoryFormat = "ansible-ini"
+	// InventoryAnsibleYAML takes the hosts of an Ansible YAML inventory
+	InventoryAnsibleYAML InventoryFormat = "ansible-yaml"
+)
+
+// InventoryFormats are the supported formats
+var InventoryFormats = []InventoryFormat{
+	InventoryList, InventoryCSV, InventoryNmapXML, InventoryNmapGrep,
+	InventoryEtcHosts, InventorySSHConfig, InventoryAnsibleINI, InventoryAnsibleYAML,
+}
+
+// ParseInventory reads the hosts of an inventory in the order they appear
+// without duplicates, the name of the file helps detecting the format
+// when it's InventoryAuto
+func ParseInventory(r io.Reader, name string, format InventoryFormat) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == InventoryAuto {
+		format = DetectInventoryFormat(name, data)
+	}
+
+	var hosts []string
+	switch format {
+	case InventoryList:
+		hosts = parseList(data)
+	case InventoryCSV:
+		hosts, err = parseCSV(data)
+	case InventoryNmapXML:
+		hosts, err = parseNmapXML(data)
+	case InventoryNmapGrep:
+		hosts = parseNmapGrep(data)
+	case InventoryEtcHosts:
+		hosts = parseEtcHosts(data)
+	case InventorySSHConfig:
+		hosts = parseSSHConfig(data)
+	case InventoryAnsibleINI:
+		hosts, err = parseAnsibleINI(data)
+	case InventoryAnsibleYAML:
+		hosts, err = parseAnsibleYAML(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidFormat, format, err)
+	}
+
+	return uniqueHosts(hosts), nil
+}
+
+// DetectInventoryFormat guesses the format of an inventory
+// from the extension of its name and its content
+func DetectInventoryFormat(name string, data []byte) InventoryFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return InventoryCSV
+	case ".xml":
+		return InventoryNmapXML
+	case ".gnmap":
+		return InventoryNmapGrep
+	case ".yaml", ".yml":
+		return InventoryAnsibleYAML
+	case ".ini":
+		return InventoryAnsibleINI
+	}
+
+	content := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(content, "<?xml"), strings.HasPrefix(content, "<nmaprun"):
+		return InventoryNmapXML
+	case strings.HasPrefix(content, "# Nmap"):
+		return InventoryNmapGrep
+	}
+
+	lines := significantLines(data)
+	if len(lines) == 0 {
+		return InventoryList
+	}
+	first := lines[0]
+	if first == "---" && len(lines) > 1 {
+		first = lines[1]
+	}
+
+	if strings.HasSuffix(first, ":") && !strings.ContainsAny(first, " \t") {
+		return InventoryAnsibleYAML
+	}
+	for _, l := range lines {
+		if isINISection(l) {
+			return InventoryAnsibleINI
+		}
+		key, _ := sshKeyword(l)
+		if key == "host" || key == "hostname" || key == "match" {
+			return InventorySSHConfig
+		}
+	}
+
+	// an address followed by names, not a list of addresses
+	if fields := strings.Fields(first); len(fields) > 1 {
+		_, errAddr := netip.ParseAddr(fields[0])
+		_, errName := netip.ParseAddr(fields[1])
+		if errAddr == nil && errName != nil && !strings.Contains(fields[1], "/") {
+			return InventoryEtcHosts
+		}
+	}
+	if strings.Contains(first, ",") && csvHostColumn(strings.Split(first, ",")) >= 0 {
+		return InventoryCSV
+	}
+
+	return InventoryList
+}
+
+// significantLines returns the trimmed lines
+// that are not empty or a comment
+func significantLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func uniqueHosts(hosts []string) []string {
+	unique := make([]string, 0, len(hosts))
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		unique = append(unique, h)
+	}
+
+	return unique
+}
+
+func parseList(data []byte) []string {
+	var hosts []string
+	for _, line := range significantLines(data) {
+		hosts = append(hosts, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})...)
+	}
+
+	return hosts
+}
+
+// csvHostColumn returns the index of the column with the hosts
+// in a header, or -1 if it's not a header
+func csvHostColumn(header []string) int {
+	for _, name := range []string{"host", "hostname", "ip", "address"} {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func parseCSV(data []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	column := csvHostColumn(records[0])
+	if column >= 0 {
+		records = records[1:]
+	} else {
+		column = 0
+	}
+
+	var hosts []string
+	for _, rec := range records {
+		if column < len(rec) {
+			hosts = append(hosts, strings.TrimSpace(rec[column]))
+		}
+	}
+
+	return hosts, nil
+}
+
+// nmapRun is the part of the nmap XML output with the hosts
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+	} `xml:"host"`
+}
+
+func parseNmapXML(data []byte) ([]string, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, h := range run.Hosts {
+		if h.Status.State != "" && h.Status.State != "up" {
+			continue
+		}
+		for _, a := range h.Addresses {
+			// the MAC addresses can't be scanned
+			if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+				hosts = append(hosts, a.Addr)
+			}
+		}
+	}
+
+	return hosts, nil
+}
+
+// parseNmapGrep parses lines like
+//
+//	Host: 10.0.0.1 (router)	Status: Up
+func parseNmapGrep(data []byte) []string {
+	var hosts []string
+	for _, line := range significantLines(data) {
+		rest, ok := strings.CutPrefix(line, "Host:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 || strings.Contains(rest, "Status: Down") {
+			continue
+		}
+		hosts = append(hosts, fields[0])
+	}
+
+	return hosts
+}
+
+func parseEtcHosts(data []byte) []string {
+	var hosts []string
+	for _, line := range significantLines(data) {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil || addr.IsLoopback() || addr.IsMulticast() || addr.IsUnspecified() {
+			continue
+		}
+		hosts = append(hosts, fields[0])
+	}
+
+	return hosts
+}
+
+// sshKeyword splits a line of a ssh config in
+// the lower case keyword and its arguments,
+// the keyword can be followed by spaces or =
+func sshKeyword(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), ""
+	}
+
+	args := strings.TrimLeft(line[i:], " \t")
+	args = strings.TrimPrefix(args, "=")
+	return strings.ToLower(line[:i]), strings.TrimSpace(args)
+}
+
+func parseSSHConfig(data []byte) []string {
+	var hosts []string
+	var aliases []string
+	hostName := ""
+	inHost := false
+
+	flush := func() {
+		if !inHost {
+			return
+		}
+		if hostName != "" && !strings.Contains(hostName, "%") {
+			hosts = append(hosts, hostName)
+		} else if hostName == "" {
+			hosts = append(hosts, aliases...)
+		}
+		aliases, hostName, inHost = nil, "", false
+	}
+
+	for _, line := range significantLines(data) {
+		key, args := sshKeyword(line)
+		switch key {
+		case "host":
+			flush()
+			inHost = true
+			for _, a := range strings.Fields(args) {
+				// patterns match many hosts and can't be scanned
+				if !strings.ContainsAny(a, "*?!") {
+					aliases = append(aliases, a)
+				}
+			}
+		case "match":
+			flush()
+		case "hostname":
+			if inHost {
+				hostName = args
+			}
+		}
+	}
+	flush()
+
+	return hosts
+}
+
+// isINISection reports if the line is a section header like [webservers],
+// unlike an IPv6 literal like [::1] the name starts with a letter
+func isINISection(line string) bool {
+	if len(line) < 3 || line[0] != '[' || line[len(line)-1] != ']' {
+		return false
+	}
+	name := line[1 : len(line)-1]
+	if strings.ContainsAny(name, "[] \t") {
+		return false
+	}
+	c := name[0]
+
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// ansibleHost returns the host of an inventory entry,
+// the ansible_host variable if it's set or else the expanded name
+func ansibleHost(name, ansibleHostVar string) ([]string, error) {
+	if ansibleHostVar != "" {
+		return []string{ansibleHostVar}, nil
+	}
+
+	return expandAnsiblePattern(name)
+}
+
+func parseAnsibleINI(data []byte) ([]string, error) {
+	var hosts []string
+	// the hosts before any section are in the ungrouped group
+	hostsSection := true
+	for _, line := range significantLines(data) {
+		if isINISection(line) {
+			// the :vars and :children sections don't have hosts
+			hostsSection = !strings.Contains(line, ":")
+			continue
+		}
+		if !hostsSection {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		ansibleHostVar := ""
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(f, "ansible_host="); ok {
+				ansibleHostVar = strings.Trim(v, `"'`)
+			}
+		}
+
+		entryHosts, err := ansibleHost(fields[0], ansibleHostVar)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, entryHosts...)
+	}
+
+	return hosts, nil
+}
+
+// ansibleGroup is a group of an Ansible YAML inventory
+type ansibleGroup struct {
+	Hosts    map[string]map[string]interface{} `yaml:"hosts"`
+	Children map[string]ansibleGroup           `yaml:"children"`
+}
+
+func parseAnsibleYAML(data []byte) ([]string, error) {
+	var groups map[string]ansibleGroup
+	if err := yaml.Unmarshal(data, &groups); err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	var walk func(groups map[string]ansibleGroup) error
+	walk = func(groups map[string]ansibleGroup) error {
+		// maps have no order, the groups and hosts are sorted
+		for _, g := range sortedKeys(groups) {
+			group := groups[g]
+			for _, name := range sortedKeys(group.Hosts) {
+				ansibleHostVar, _ := group.Hosts[name]["ansible_host"].(string)
+				entryHosts, err := ansibleHost(name, ansibleHostVar)
+				if err != nil {
+					return err
+				}
+				hosts = append(hosts, entryHosts...)
+			}
+			if err := walk(group.Children); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(groups); err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// expandAnsiblePattern expands the ranges of an Ansible host pattern
+// like web[01:03].example.com or db-[a:c], with an optional step
+// like [1:10:2]
+func expandAnsiblePattern(pattern string) ([]string, error) {
+	start := strings.Index(pattern, "[")
+	if start < 0 {
+		return []string{pattern}, nil
+	}
+	end := strings.Index(pattern[start:], "]")
+	if end < 0 {
+		return nil, fmt.Errorf("unclosed range in %s", pattern)
+	}
+	end += start
+
+	values, err := expandAnsibleRange(pattern[start+1 : end])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pattern, err)
+	}
+
+	rest, err := expandAnsiblePattern(pattern[end+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, v := range values {
+		for _, r := range rest {
+			hosts = append(hosts, pattern[:start]+v+r)
+		}
+	}
+
+	return hosts, nil
+}
+
+func expandAnsibleRange(r string) ([]string, error) {
+	parts := strings.Split(r, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid range [%s]", r)
+	}
+
+	step := 1
+	if len(parts) == 3 {
+		s, err := strconv.Atoi(parts[2])
+		if err != nil || s < 1 {
+			return nil, fmt.Errorf("invalid step in [%s]", r)
+		}
+		step = s
+	}
+
+	first, last := parts[0], parts[1]
+	if isNumeric(first) && isNumeric(last) {
+		from, _ := strconv.Atoi(first)
+		to, _ := strconv.Atoi(last)
+		if from > to {
+			return nil, fmt.Errorf("invalid range [%s]", r)
+		}
+		var values []string
+		for i := from; i <= to; i += step {
+			// [01:10] keeps the leading zeros
+			values = append(values, fmt.Sprintf("%0*d", len(first), i))
+		}
+		return values, nil
+	}
+
+	if len(first) == 1 && len(last) == 1 && first <= last {
+		var values []string
+		for c := int(first[0]); c <= int(last[0]); c += step {
+			values = append(values, string(rune(c)))
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("invalid range [%s]", r)
+}
@@ -0,0 +1,152 @@
+package scan_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+const nmapXML = `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap">
+<host><status state="up" reason="arp-response"/>
+<address addr="10.0.0.1" addrtype="ipv4"/>
+<address addr="00:11:22:33:44:55" addrtype="mac"/>
+<hostnames><hostname name="router" type="PTR"/></hostnames>
+</host>
+<host><status state="down"/><address addr="10.0.0.2" addrtype="ipv4"/></host>
+<host><status state="up"/><address addr="fd00::3" addrtype="ipv6"/></host>
+</nmaprun>
+`
+
+const nmapGrep = `# Nmap 7.94 scan initiated as: nmap -oG - 10.0.0.0/30
+Host: 10.0.0.1 (router)	Status: Up
+Host: 10.0.0.1 (router)	Ports: 22/open/tcp//ssh///
+Host: 10.0.0.2 ()	Status: Down
+Host: 10.0.0.3 ()	Status: Up
+# Nmap done at Mon -- 4 IP addresses (2 hosts up) scanned
+`
+
+const etcHosts = `127.0.0.1	localhost
+::1	localhost ip6-localhost
+ff02::1	ip6-allnodes
+# the lab
+10.0.0.5	db1.lab db1 # primary
+fd00::6	db2.lab
+`
+
+const sshConfig = `Host *
+    ServerAliveInterval 60
+
+Host bastion
+    HostName bastion.example.com
+    User admin
+
+Host web1 web2
+    Port 2222
+
+Host db
+    HostName=10.0.0.7
+
+Match host *.internal
+    HostName ignored.example.com
+`
+
+const ansibleINI = `mail.example.com
+
+[webservers]
+web[01:03].example.com
+jumper ansible_port=5555 ansible_host=192.0.2.50
+
+[dbservers]
+db-[a:b].example.com
+
+[dbservers:vars]
+ntp_server=ntp.example.com
+
+[datacenter:children]
+webservers
+dbservers
+`
+
+const ansibleYAML = `all:
+  hosts:
+    mail.example.com:
+  children:
+    webservers:
+      hosts:
+        web[1:5:2].example.com:
+        jumper:
+          ansible_port: 5555
+          ansible_host: 192.0.2.50
+    dbservers:
+      hosts:
+        db1.example.com:
+`
+
+func TestParseInventory(t *testing.T) {
+	testCases := []struct {
+		name        string
+		file        string
+		content     string
+		format      scan.InventoryFormat
+		expectHosts []string
+	}{
+		{"List", "", "# hosts\nhost1, host2\nhost3 host1\n", scan.InventoryAuto,
+			[]string{"host1", "host2", "host3"}},
+		{"CSVHeader", "hosts.csv", "name,IP,owner\nweb,10.0.0.1,ops\ndb,10.0.0.2,dba\n", scan.InventoryAuto,
+			[]string{"10.0.0.1", "10.0.0.2"}},
+		{"CSVNoHeader", "", "host1,ops\nhost2,dba\n", scan.InventoryCSV,
+			[]string{"host1", "host2"}},
+		{"NmapXML", "", nmapXML, scan.InventoryAuto,
+			[]string{"10.0.0.1", "fd00::3"}},
+		{"NmapGrep", "", nmapGrep, scan.InventoryAuto,
+			[]string{"10.0.0.1", "10.0.0.3"}},
+		{"EtcHosts", "", etcHosts, scan.InventoryAuto,
+			[]string{"10.0.0.5", "fd00::6"}},
+		{"SSHConfig", "config", sshConfig, scan.InventoryAuto,
+			[]string{"bastion.example.com", "web1", "web2", "10.0.0.7"}},
+		{"AnsibleINI", "hosts", ansibleINI, scan.InventoryAuto,
+			[]string{"mail.example.com", "web01.example.com", "web02.example.com", "web03.example.com",
+				"192.0.2.50", "db-a.example.com", "db-b.example.com"}},
+		{"AnsibleYAML", "inventory", ansibleYAML, scan.InventoryAuto,
+			[]string{"mail.example.com", "db1.example.com", "192.0.2.50",
+				"web1.example.com", "web3.example.com", "web5.example.com"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hosts, err := scan.ParseInventory(strings.NewReader(tc.content), tc.file, tc.format)
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if !reflect.DeepEqual(hosts, tc.expectHosts) {
+				t.Errorf("Expected hosts %q, got %q instead\n", tc.expectHosts, hosts)
+			}
+		})
+	}
+}
+
+func TestParseInventoryErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		format    scan.InventoryFormat
+		expectErr error
+	}{
+		{"UnknownFormat", "host1\n", "toml", scan.ErrUnknownFormat},
+		{"InvalidXML", "<nmaprun><host>", scan.InventoryNmapXML, scan.ErrInvalidFormat},
+		{"InvalidRange", "[web]\nweb[3:1]\n", scan.InventoryAnsibleINI, scan.ErrInvalidFormat},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := scan.ParseInventory(strings.NewReader(tc.content), "", tc.format)
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected error %q, got %v instead\n", tc.expectErr, err)
+			}
+		})
+	}
+}
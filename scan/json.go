@@ -0,0 +1,159 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON schema of the results,
+// it changes only when fields are removed or change meaning
+const SchemaVersion = 1
+
+// Report is the JSON document of a whole scan
+type Report struct {
+	SchemaVersion int           `json:"schema_version"`
+	Start         time.Time     `json:"start"`
+	Elapsed       time.Duration `json:"elapsed_ns"`
+	Results       []Results     `json:"results"`
+}
+
+// Record is the JSON document of a single host,
+// a stream of scan results has a record per line
+type Record struct {
+	SchemaVersion int     `json:"schema_version"`
+	Result        Results `json:"result"`
+}
+
+// jsonError is an error written in JSON as its message
+type jsonError struct {
+	err error
+}
+
+// newJSONError returns nil for a nil error so the field is left out
+func newJSONError(err error) *jsonError {
+	if err == nil {
+		return nil
+	}
+
+	return &jsonError{err: err}
+}
+
+// unwrap returns the error, nil if the field was not set
+func (e *jsonError) unwrap() error {
+	if e == nil {
+		return nil
+	}
+
+	return e.err
+}
+
+// MarshalJSON implements json.Marshaler
+func (e jsonError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.err.Error())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *jsonError) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	if msg != "" {
+		e.err = errors.New(msg)
+	}
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, the error is written as its message
+// and the empty lists as [] instead of null
+func (r Results) MarshalJSON() ([]byte, error) {
+	// results has the fields but not the methods of Results
+	type results Results
+	if r.Addresses == nil {
+		r.Addresses = []string{}
+	}
+	if r.PortStates == nil {
+		r.PortStates = []PortState{}
+	}
+
+	return json.Marshal(struct {
+		results
+		Err *jsonError `json:"error,omitempty"`
+	}{results(r), newJSONError(r.Err)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *Results) UnmarshalJSON(data []byte) error {
+	type results Results
+	aux := struct {
+		*results
+		Err *jsonError `json:"error,omitempty"`
+	}{results: (*results)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.Err = aux.Err.unwrap()
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, the error is written as its message
+func (p PortState) MarshalJSON() ([]byte, error) {
+	type portState PortState
+	return json.Marshal(struct {
+		portState
+		Err *jsonError `json:"error,omitempty"`
+	}{portState(p), newJSONError(p.Err)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *PortState) UnmarshalJSON(data []byte) error {
+	type portState PortState
+	aux := struct {
+		*portState
+		Err *jsonError `json:"error,omitempty"`
+	}{portState: (*portState)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	p.Err = aux.Err.unwrap()
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *State) UnmarshalText(text []byte) error {
+	for _, st := range []State{StateClosed, StateOpen, StateFiltered, StateOpenFiltered} {
+		if st.String() == string(text) {
+			*s = st
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown port state %q", text)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s HostStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *HostStatus) UnmarshalText(text []byte) error {
+	for _, st := range []HostStatus{HostUnknown, HostUp, HostDown} {
+		if st.String() == string(text) {
+			*s = st
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown host status %q", text)
+}
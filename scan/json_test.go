@@ -0,0 +1,81 @@
+package scan_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+func TestResultsJSON(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	r := scan.Results{
+		Host:      "localhost",
+		Addresses: []string{"127.0.0.1"},
+		Status:    scan.HostUp,
+		Start:     start,
+		Elapsed:   2 * time.Millisecond,
+		PortStates: []scan.PortState{
+			{Port: 22, Protocol: scan.ProtocolTcp, State: scan.StateOpen, Attempts: 1, Banner: "SSH-2.0"},
+			{Port: 53, Protocol: scan.ProtocolUdp, State: scan.StateOpenFiltered, Attempts: 2,
+				Err: errors.New("i/o timeout"), Elapsed: time.Millisecond},
+		},
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	expected := `{"host":"localhost","addresses":["127.0.0.1"],"not_found":false,"status":"up","pings":0,` +
+		`"start":"2024-05-01T10:00:00Z","elapsed_ns":2000000,"ports":[` +
+		`{"port":22,"protocol":"tcp","state":"open","attempts":1,"banner":"SSH-2.0","elapsed_ns":0},` +
+		`{"port":53,"protocol":"udp","state":"open|filtered","attempts":2,"elapsed_ns":1000000,"error":"i/o timeout"}]}`
+	if string(data) != expected {
+		t.Errorf("Expected JSON %s, got %s instead\n", expected, data)
+	}
+
+	var decoded scan.Results
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if decoded.PortStates[1].Err == nil || decoded.PortStates[1].Err.Error() != "i/o timeout" {
+		t.Errorf("Expected error %q, got %v instead\n", "i/o timeout", decoded.PortStates[1].Err)
+	}
+	decoded.PortStates[1].Err = r.PortStates[1].Err
+	if !reflect.DeepEqual(decoded, r) {
+		t.Errorf("Expected decoded results %v, got %v instead\n", r, decoded)
+	}
+}
+
+func TestResultsJSONNotFound(t *testing.T) {
+	r := scan.Results{Host: "gone", NotFound: true, Err: errors.New("no such host")}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if !strings.Contains(string(data), `"error":"no such host"`) || !strings.Contains(string(data), `"ports":[]`) {
+		t.Errorf("Expected the error message and no ports, got %s instead\n", data)
+	}
+
+	var decoded scan.Results
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if !decoded.NotFound || decoded.Err == nil || decoded.Err.Error() != "no such host" {
+		t.Errorf("Expected host not found with error %q, got %+v instead\n", "no such host", decoded)
+	}
+}
+
+func TestStateUnmarshalInvalid(t *testing.T) {
+	var p scan.PortState
+	err := json.Unmarshal([]byte(`{"port":22,"state":"half-open"}`), &p)
+	if err == nil || !strings.Contains(err.Error(), "half-open") {
+		t.Errorf("Expected error for unknown state, got %v instead\n", err)
+	}
+}
@@ -0,0 +1,56 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LogLevel is the verbosity of the scan diagnostics
+type LogLevel int
+
+const (
+	// LogInfo is for messages always worth showing
+	LogInfo LogLevel = iota
+	// LogVerbose is for the progress of the scan
+	LogVerbose
+	// LogDebug is for the details of every probe
+	LogDebug
+)
+
+// Logger receives the diagnostics of a scan,
+// it is called from multiple goroutines
+type Logger interface {
+	Logf(level LogLevel, format string, args ...any)
+}
+
+type writerLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level LogLevel
+}
+
+// NewLogger returns a Logger writing to w
+// the messages up to the given level, one per line
+func NewLogger(w io.Writer, level LogLevel) Logger {
+	return &writerLogger{w: w, level: level}
+}
+
+func (l *writerLogger) Logf(level LogLevel, format string, args ...any) {
+	if level > l.level {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, format+"\n", args...)
+}
+
+// logf sends a message to the logger of the configuration, if any
+func (cfg *ScanCfg) logf(level LogLevel, format string, args ...any) {
+	if cfg.Logger == nil {
+		return
+	}
+
+	cfg.Logger.Logf(level, format, args...)
+}
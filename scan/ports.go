@@ -0,0 +1,152 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	minPort = 1
+	maxPort = 65535
+)
+
+var (
+	ErrInvalidPort    = errors.New("invalid port")
+	ErrInvalidRange   = errors.New("invalid port range")
+	ErrUnknownService = errors.New("unknown service")
+)
+
+// PortSpecError is returned when a part of a port specification can't be parsed
+type PortSpecError struct {
+	Spec string
+	Err  error
+}
+
+func (e *PortSpecError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Err, e.Spec)
+}
+
+func (e *PortSpecError) Unwrap() error {
+	return e.Err
+}
+
+// ParsePorts parses port specifications into a sorted list of unique ports.
+// Every specification is a comma separated list of:
+//
+//	22         a single port
+//	22-443     a range of ports
+//	-1024      a range starting from the first port
+//	60000-     a range ending with the last port
+//	ssh        a service name from /etc/services
+//	!25        a port, range or service excluded from the others
+func ParsePorts(specs []string) ([]int, error) {
+	included := make([]bool, maxPort+1)
+	excluded := make([]bool, maxPort+1)
+
+	for _, spec := range specs {
+		for _, part := range strings.Split(spec, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			set := included
+			if strings.HasPrefix(part, "!") {
+				set = excluded
+				part = strings.TrimSpace(part[1:])
+			}
+
+			first, last, err := parsePortRange(part)
+			if err != nil {
+				return nil, &PortSpecError{Spec: part, Err: err}
+			}
+
+			for p := first; p <= last; p++ {
+				set[p] = true
+			}
+		}
+	}
+
+	ports := []int{}
+	for p := minPort; p <= maxPort; p++ {
+		if included[p] && !excluded[p] {
+			ports = append(ports, p)
+		}
+	}
+
+	return ports, nil
+}
+
+// parsePortRange parses a single port, service or range of ports
+func parsePortRange(part string) (int, int, error) {
+	if !strings.Contains(part, "-") || !isNumeric(strings.ReplaceAll(part, "-", "")) {
+		// service names can contain dashes too, like ms-wbt-server
+		p, err := parsePort(part)
+		return p, p, err
+	}
+
+	bounds := strings.Split(part, "-")
+	if len(bounds) != 2 {
+		return 0, 0, ErrInvalidRange
+	}
+
+	first, last := minPort, maxPort
+	var err error
+	if bounds[0] != "" {
+		if first, err = parsePort(bounds[0]); err != nil {
+			return 0, 0, err
+		}
+	}
+	if bounds[1] != "" {
+		if last, err = parsePort(bounds[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if first > last {
+		return 0, 0, ErrInvalidRange
+	}
+
+	return first, last, nil
+}
+
+// parsePort parses a port number or a service name
+func parsePort(p string) (int, error) {
+	if !isNumeric(p) {
+		port, ok := lookupService(p)
+		if !ok {
+			return 0, ErrUnknownService
+		}
+		return port, nil
+	}
+
+	port, err := strconv.Atoi(p)
+	if err != nil || !isPortValid(port) {
+		return 0, ErrInvalidPort
+	}
+
+	return port, nil
+}
+
+func isPortValid(port int) bool {
+	if port < minPort || port > maxPort {
+		return false
+	}
+
+	return true
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,94 @@
+package scan_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		name      string
+		specs     []string
+		expect    []int
+		expectLen int
+		expectErr error
+	}{
+		{name: "Single", specs: []string{"22"}, expect: []int{22}},
+		{name: "Range", specs: []string{"20-23"}, expect: []int{20, 21, 22, 23}},
+		{name: "CommaList", specs: []string{"80,22, 443"}, expect: []int{22, 80, 443}},
+		{name: "Dedup", specs: []string{"22-24", "23", "24,22"}, expect: []int{22, 23, 24}},
+		{name: "OpenStart", specs: []string{"-1024"}, expectLen: 1024},
+		{name: "OpenEnd", specs: []string{"65530-"}, expect: []int{65530, 65531, 65532, 65533, 65534, 65535}},
+		{name: "Exclusion", specs: []string{"20-25", "!21-23"}, expect: []int{20, 24, 25}},
+		{name: "Services", specs: []string{"https,ssh"}, expect: []int{22, 443}},
+		{name: "ExcludedService", specs: []string{"20-25", "!ssh"}, expect: []int{20, 21, 23, 24, 25}},
+		{name: "Empty", specs: []string{}, expect: []int{}},
+		{name: "ReversedRange", specs: []string{"443-22"}, expectErr: scan.ErrInvalidRange},
+		{name: "DoubleRange", specs: []string{"1-2-3"}, expectErr: scan.ErrInvalidRange},
+		{name: "PortZero", specs: []string{"0"}, expectErr: scan.ErrInvalidPort},
+		{name: "PortTooBig", specs: []string{"22-65536"}, expectErr: scan.ErrInvalidPort},
+		{name: "UnknownService", specs: []string{"notaservice"}, expectErr: scan.ErrUnknownService},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := scan.ParsePorts(tc.specs)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("Expected error %q, got %q instead\n", tc.expectErr, err)
+				}
+				var specErr *scan.PortSpecError
+				if !errors.As(err, &specErr) {
+					t.Errorf("Expected a PortSpecError, got %T instead\n", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+
+			if tc.expectLen > 0 {
+				if len(ports) != tc.expectLen {
+					t.Errorf("Expected %d ports, got %d instead\n", tc.expectLen, len(ports))
+				}
+				return
+			}
+			if !reflect.DeepEqual(ports, tc.expect) {
+				t.Errorf("Expected ports %v, got %v instead\n", tc.expect, ports)
+			}
+		})
+	}
+}
+
+func TestRunInvalidPorts(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	_, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{"443-22"}, Tcp: true})
+	if !errors.Is(err, scan.ErrInvalidRange) {
+		t.Errorf("Expected error %q, got %q instead\n", scan.ErrInvalidRange, err)
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	testCases := []struct {
+		port     int
+		protocol string
+		expect   string
+	}{
+		{22, "tcp", "ssh"},
+		{443, "TCP", "https"},
+		{0, "tcp", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expect, func(t *testing.T) {
+			if name := scan.ServiceName(tc.port, tc.protocol); name != tc.expect {
+				t.Errorf("Expected service %q, got %q instead\n", tc.expect, name)
+			}
+		})
+	}
+}
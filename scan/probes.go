@@ -0,0 +1,208 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidProbe = errors.New("invalid probe")
+
+// Probe is the payload sent to a UDP port
+type Probe struct {
+	// Name is the name of the service expected to answer
+	Name    string
+	Payload []byte
+	// Match checks that a response comes from the expected service,
+	// a nil Match accepts any response
+	Match func(resp []byte) bool
+}
+
+// ProbeTable maps ports to the probe sent to them
+type ProbeTable map[int]Probe
+
+// genericProbe is sent to the ports without a probe in the table
+var genericProbe = Probe{Payload: []byte("c")}
+
+// dnsProbe asks for the version.bind TXT record of the CHAOS class,
+// any DNS server answers it even if it's only with an error
+var dnsProbe = []byte{
+	0x13, 0x37, // id
+	0x01, 0x00, // standard query, recursion desired
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // 1 question
+	0x07, 'v', 'e', 'r', 's', 'i', 'o', 'n',
+	0x04, 'b', 'i', 'n', 'd', 0x00,
+	0x00, 0x10, // TXT
+	0x00, 0x03, // CHAOS
+}
+
+// ntpProbe is a NTP version 4 client request
+var ntpProbe = append([]byte{0xe3}, make([]byte, 47)...)
+
+// snmpProbe is a SNMP v1 get-request of sysDescr.0 with the public community
+var snmpProbe = []byte{
+	0x30, 0x26, 0x02, 0x01, 0x00,
+	0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+	0xa0, 0x19, 0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00,
+	0x30, 0x0e, 0x30, 0x0c,
+	0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00,
+	0x05, 0x00,
+}
+
+// netbiosProbe is a NetBIOS name status request for the wildcard name
+var netbiosProbe = append([]byte{
+	0x13, 0x37, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x20, 'C', 'K'},
+	append(bytes.Repeat([]byte{'A'}, 30), 0x00, 0x00, 0x21, 0x00, 0x01)...)
+
+var ssdpProbe = []byte("M-SEARCH * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"MAN: \"ssdp:discover\"\r\n" +
+	"MX: 1\r\n" +
+	"ST: ssdp:all\r\n\r\n")
+
+// matchID checks that the response starts with the same 2 bytes id as the request,
+// used by DNS like protocols
+func matchID(req []byte) func([]byte) bool {
+	return func(resp []byte) bool {
+		return len(resp) >= 12 && bytes.Equal(resp[:2], req[:2])
+	}
+}
+
+func matchPrefix(prefix []byte) func([]byte) bool {
+	return func(resp []byte) bool {
+		return bytes.HasPrefix(resp, prefix)
+	}
+}
+
+// DefaultProbes returns the table of the built in probes
+func DefaultProbes() ProbeTable {
+	return ProbeTable{
+		53: {Name: "domain", Payload: dnsProbe, Match: matchID(dnsProbe)},
+		69: {
+			Name:    "tftp",
+			Payload: []byte("\x00\x01pscanner.txt\x00octet\x00"),
+			Match: func(resp []byte) bool {
+				// data or error packet
+				return len(resp) >= 4 && resp[0] == 0 && (resp[1] == 3 || resp[1] == 5)
+			},
+		},
+		123: {
+			Name:    "ntp",
+			Payload: ntpProbe,
+			Match: func(resp []byte) bool {
+				// server mode
+				return len(resp) >= 48 && resp[0]&0x07 == 4
+			},
+		},
+		137:  {Name: "netbios-ns", Payload: netbiosProbe, Match: matchID(netbiosProbe)},
+		161:  {Name: "snmp", Payload: snmpProbe, Match: matchPrefix([]byte{0x30})},
+		1900: {Name: "ssdp", Payload: ssdpProbe, Match: matchPrefix([]byte("HTTP/1.1"))},
+		5353: {Name: "mdns", Payload: dnsProbe, Match: matchID(dnsProbe)},
+		11211: {
+			Name:    "memcache",
+			Payload: []byte("\x00\x01\x00\x00\x00\x01\x00\x00stats\r\n"),
+			Match:   matchPrefix([]byte{0x00, 0x01}),
+		},
+	}
+}
+
+// probe returns the probe for a port
+func (pt ProbeTable) probe(port int) Probe {
+	if p, ok := pt[port]; ok {
+		return p
+	}
+
+	return genericProbe
+}
+
+// Load adds the probes from a file to the table, replacing the existing ones.
+// Every line of the file has the format
+//
+//	<ports> <name> <payload> [<response prefix>]
+//
+// where ports is a port specification like for ParsePorts,
+// payload and response prefix are Go quoted strings, e.g. "\x00\x01".
+// Empty lines and lines starting with # are ignored.
+func (pt ProbeTable) Load(probesFile string) error {
+	f, err := os.Open(probesFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ports, p, err := parseProbe(line)
+		if err != nil {
+			return fmt.Errorf("%w: %s:%d: %s", ErrInvalidProbe, probesFile, lineNo, err)
+		}
+
+		for _, port := range ports {
+			pt[port] = p
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseProbe parses a line of a probes file
+func parseProbe(line string) ([]int, Probe, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return nil, Probe{}, fmt.Errorf("expected <ports> <name> <payload>")
+	}
+
+	ports, err := ParsePorts(fields[:1])
+	if err != nil {
+		return nil, Probe{}, err
+	}
+
+	// the quoted strings can contain spaces
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[len(fields[0]):]), fields[1]))
+	payload, rest, err := unquotePrefix(rest)
+	if err != nil {
+		return nil, Probe{}, fmt.Errorf("payload: %w", err)
+	}
+
+	p := Probe{Name: fields[1], Payload: []byte(payload)}
+	if rest = strings.TrimSpace(rest); rest != "" {
+		prefix, rest, err := unquotePrefix(rest)
+		if err != nil {
+			return nil, Probe{}, fmt.Errorf("response prefix: %w", err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, Probe{}, fmt.Errorf("unexpected %q", rest)
+		}
+		p.Match = matchPrefix([]byte(prefix))
+	}
+
+	return ports, p, nil
+}
+
+// unquotePrefix unquotes the quoted string at the start of s
+// and returns the rest of s after it
+func unquotePrefix(s string) (string, string, error) {
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", "", err
+	}
+
+	unquoted, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", "", err
+	}
+
+	return unquoted, s[len(quoted):], nil
+}
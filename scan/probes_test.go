@@ -0,0 +1,172 @@
+package scan_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+// udpServer answers every packet with the result of respond,
+// it stays silent if respond returns nil
+func udpServer(t *testing.T, respond func(req []byte) []byte) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := respond(buf[:n]); resp != nil {
+				conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+
+	_, portStr, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestRunUdpProbes(t *testing.T) {
+	defaults := scan.DefaultProbes()
+
+	// stands in for a DNS server, answers with the id of the query
+	dns := udpServer(t, func(req []byte) []byte {
+		if !bytes.Equal(req, defaults[53].Payload) {
+			return nil
+		}
+		return append([]byte{req[0], req[1], 0x81, 0x05}, make([]byte, 8)...)
+	})
+	// stands in for a NTP server, answers in server mode
+	ntp := udpServer(t, func(req []byte) []byte {
+		if !bytes.Equal(req, defaults[123].Payload) {
+			return nil
+		}
+		return append([]byte{0x24}, make([]byte, 47)...)
+	})
+	// answers something that is not NTP
+	other := udpServer(t, func(req []byte) []byte {
+		return []byte("hello")
+	})
+
+	testCases := []struct {
+		name          string
+		port          int
+		probe         scan.Probe
+		expectState   scan.State
+		expectService string
+	}{
+		{"DNS", dns, defaults[53], scan.StateOpen, "domain"},
+		{"NTP", ntp, defaults[123], scan.StateOpen, "ntp"},
+		{"NoMatch", other, defaults[123], scan.StateOpen, ""},
+		// a DNS server does not answer the NTP probe
+		{"WrongProbe", dns, defaults[123], scan.StateOpenFiltered, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			hl.Add("localhost")
+
+			cfg := &scan.ScanCfg{
+				Ports:      []string{strconv.Itoa(tc.port)},
+				Udp:        true,
+				UdpTimeout: 100 * time.Millisecond,
+				UdpProbes:  scan.ProbeTable{tc.port: tc.probe},
+			}
+			res, err := scan.Run(hl, cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if len(res) != 1 || len(res[0].PortStates) != 1 {
+				t.Fatalf("Expected 1 port state, got %v instead\n", res)
+			}
+			ps := res[0].PortStates[0]
+			if ps.State != tc.expectState {
+				t.Errorf("Expected state %q, got %q instead\n", tc.expectState, ps.State)
+			}
+			if ps.Service != tc.expectService {
+				t.Errorf("Expected service %q, got %q instead\n", tc.expectService, ps.Service)
+			}
+		})
+	}
+}
+
+func TestProbesLoad(t *testing.T) {
+	tf, err := os.CreateTemp("", "probes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tf.Name())
+
+	content := `# custom probes
+9000,9001 custom "hello world\x00" "HI"
+
+53 mydns "\x13\x37"
+`
+	if _, err := tf.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	tf.Close()
+
+	pt := scan.DefaultProbes()
+	if err := pt.Load(tf.Name()); err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	for _, port := range []int{9000, 9001} {
+		p, ok := pt[port]
+		if !ok {
+			t.Fatalf("Expected a probe for port %d\n", port)
+		}
+		if p.Name != "custom" || string(p.Payload) != "hello world\x00" {
+			t.Errorf("Expected probe custom, got %q %q instead\n", p.Name, p.Payload)
+		}
+		if p.Match == nil || !p.Match([]byte("HI there")) || p.Match([]byte("bye")) {
+			t.Errorf("Expected probe to match responses starting with %q\n", "HI")
+		}
+	}
+	if pt[53].Name != "mydns" || pt[53].Match != nil {
+		t.Errorf("Expected probe for port 53 to be replaced\n")
+	}
+	if _, ok := pt[123]; !ok {
+		t.Errorf("Expected default probes to be kept\n")
+	}
+}
+
+func TestProbesLoadInvalid(t *testing.T) {
+	tf, err := os.CreateTemp("", "probes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tf.Name())
+
+	if _, err := tf.WriteString("9000 custom hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	tf.Close()
+
+	pt := scan.ProbeTable{}
+	if err := pt.Load(tf.Name()); !errors.Is(err, scan.ErrInvalidProbe) {
+		t.Errorf("Expected error %q, got %q instead\n", scan.ErrInvalidProbe, err)
+	}
+}
@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket that lets through rate probes per second,
+// with bursts of up to a tenth of a second worth of probes
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter for the rate,
+// it returns nil, which never waits, if the rate is not set
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	burst := math.Max(1, math.Floor(rate/10))
+	return &limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait takes a token from the bucket, waiting for it if the bucket is empty
+// the tokens are reserved in order so the waiting probes are not starved
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ProbeRate returns the number of probes sent for the results,
+// discovery pings included, and the rate they were sent at during elapsed
+func ProbeRate(results []Results, elapsed time.Duration) (int, float64) {
+	probes := 0
+	for _, r := range results {
+		probes += r.Pings
+		for _, p := range r.PortStates {
+			probes += p.Attempts
+		}
+	}
+
+	if elapsed <= 0 {
+		return probes, 0
+	}
+
+	return probes, float64(probes) / elapsed.Seconds()
+}
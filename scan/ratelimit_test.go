@@ -0,0 +1,61 @@
+package scan_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+func TestRunMaxRate(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	ports := []string{}
+	for i := 0; i < 10; i++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		_, portStr, err := net.SplitHostPort(ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ports = append(ports, portStr)
+	}
+
+	testCases := []struct {
+		name string
+		cfg  *scan.ScanCfg
+	}{
+		{"MaxRate", &scan.ScanCfg{Ports: ports, Tcp: true, MaxRate: 50}},
+		{"HostRate", &scan.ScanCfg{Ports: ports, Tcp: true, HostRate: 50}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			res, err := scan.Run(hl, tc.cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			elapsed := time.Since(start)
+
+			// 5 probes go in the first burst, the other 5 are 20ms apart
+			if elapsed < 80*time.Millisecond {
+				t.Errorf("Expected the scan to take at least 80ms, took %s instead\n", elapsed)
+			}
+
+			// the discovery pings count too
+			probes, rate := scan.ProbeRate(res, elapsed)
+			if res[0].Pings == 0 || probes != len(ports)+res[0].Pings {
+				t.Errorf("Expected %d probes and the pings, got %d instead\n", len(ports), probes)
+			}
+			if rate > 100 {
+				t.Errorf("Expected a rate close to 50 probes/s, got %.1f instead\n", rate)
+			}
+		})
+	}
+}
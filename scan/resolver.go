@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Resolver resolves host names to addresses,
+// *net.Resolver implements it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NewDNSResolver returns a Resolver that asks the DNS server
+// instead of the system resolver, the port defaults to 53
+func NewDNSResolver(server string) Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// StaticResolver resolves the names in Hosts to their addresses,
+// like the entries of /etc/hosts, and the other names with Fallback
+type StaticResolver struct {
+	Hosts map[string][]string
+	// Fallback resolves the names missing from Hosts,
+	// they are not found if it's not set
+	Fallback Resolver
+}
+
+func (s *StaticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	name := strings.TrimSuffix(strings.ToLower(host), ".")
+	for h, addrs := range s.Hosts {
+		if strings.TrimSuffix(strings.ToLower(h), ".") == name && len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+
+	if s.Fallback == nil {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return s.Fallback.LookupHost(ctx, host)
+}
+
+// cachingResolver remembers the answers of a resolver for the length of a scan,
+// concurrent lookups of the same name wait for the first one
+type cachingResolver struct {
+	resolver Resolver
+	mu       sync.Mutex
+	cache    map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	done  chan struct{}
+	addrs []string
+	err   error
+}
+
+func newCachingResolver(r Resolver) *cachingResolver {
+	return &cachingResolver{
+		resolver: r,
+		cache:    map[string]*cacheEntry{},
+	}
+}
+
+func (c *cachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	c.mu.Lock()
+	e, ok := c.cache[host]
+	if !ok {
+		e = &cacheEntry{done: make(chan struct{})}
+		c.cache[host] = e
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		e.addrs, e.err = c.resolver.LookupHost(ctx, host)
+		if ctx.Err() != nil {
+			// don't keep the answers of cancelled lookups
+			c.mu.Lock()
+			delete(c.cache, host)
+			c.mu.Unlock()
+		}
+		close(e.done)
+		return e.addrs, e.err
+	}
+
+	select {
+	case <-e.done:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
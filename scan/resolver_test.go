@@ -0,0 +1,184 @@
+package scan_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+// dnsServer stands in for a DNS server answering
+// the A queries for the names in records
+func dnsServer(t *testing.T, records map[string]net.IP) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := dnsAnswer(buf[:n], records); resp != nil {
+				conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func dnsAnswer(req []byte, records map[string]net.IP) []byte {
+	if len(req) < 12 {
+		return nil
+	}
+
+	// read the name of the first question
+	name := ""
+	i := 12
+	for i < len(req) && req[i] != 0 {
+		l := int(req[i])
+		if i+1+l > len(req) {
+			return nil
+		}
+		if name != "" {
+			name += "."
+		}
+		name += string(req[i+1 : i+1+l])
+		i += 1 + l
+	}
+	if i+5 > len(req) {
+		return nil
+	}
+	question := req[12 : i+5]
+	qtype := binary.BigEndian.Uint16(req[i+1 : i+3])
+
+	ip, ok := records[name]
+	rcode := byte(0)
+	if !ok {
+		// name error
+		rcode = 3
+	}
+
+	resp := []byte{req[0], req[1], 0x81, 0x80 | rcode, 0, 1, 0, 0, 0, 0, 0, 0}
+	resp = append(resp, question...)
+	if ok && qtype == 1 {
+		resp[7] = 1
+		resp = append(resp, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		resp = append(resp, ip.To4()...)
+	}
+
+	return resp
+}
+
+func TestDNSResolver(t *testing.T) {
+	server := dnsServer(t, map[string]net.IP{"db.internal": net.ParseIP("10.1.2.3")})
+	r := scan.NewDNSResolver(server)
+
+	addrs, err := r.LookupHost(context.Background(), "db.internal")
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.1.2.3"}) {
+		t.Errorf("Expected addresses %v, got %v instead\n", []string{"10.1.2.3"}, addrs)
+	}
+
+	if _, err := r.LookupHost(context.Background(), "missing.internal"); err == nil {
+		t.Errorf("Expected an error for a missing name\n")
+	}
+}
+
+func TestStaticResolver(t *testing.T) {
+	server := dnsServer(t, map[string]net.IP{"db.internal": net.ParseIP("10.1.2.3")})
+	r := &scan.StaticResolver{
+		Hosts:    map[string][]string{"web.internal": {"10.0.0.1", "10.0.0.2"}},
+		Fallback: scan.NewDNSResolver(server),
+	}
+
+	testCases := []struct {
+		name        string
+		host        string
+		expectAddrs []string
+	}{
+		{"Static", "web.internal", []string{"10.0.0.1", "10.0.0.2"}},
+		{"StaticCase", "WEB.internal.", []string{"10.0.0.1", "10.0.0.2"}},
+		{"Fallback", "db.internal", []string{"10.1.2.3"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addrs, err := r.LookupHost(context.Background(), tc.host)
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if !reflect.DeepEqual(addrs, tc.expectAddrs) {
+				t.Errorf("Expected addresses %v, got %v instead\n", tc.expectAddrs, addrs)
+			}
+		})
+	}
+
+	noFallback := &scan.StaticResolver{}
+	_, err := noFallback.LookupHost(context.Background(), "web.internal")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("Expected a not found error, got %q instead\n", err)
+	}
+}
+
+func TestRunResolver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := dnsServer(t, map[string]net.IP{"db.internal": net.ParseIP("127.0.0.1")})
+	resolver := &scan.StaticResolver{
+		Hosts:    map[string][]string{"multi.internal": {"127.0.0.1", "127.0.0.2"}},
+		Fallback: scan.NewDNSResolver(server),
+	}
+
+	hl := &scan.HostsList{}
+	for _, h := range []string{"db.internal", "multi.internal"} {
+		hl.Add(h)
+	}
+
+	cfg := &scan.ScanCfg{Ports: []string{portStr}, Tcp: true, AllAddresses: true, Resolver: resolver}
+	res, err := scan.Run(hl, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	expect := []struct {
+		host    string
+		address string
+		state   scan.State
+	}{
+		{"db.internal", "127.0.0.1", scan.StateOpen},
+		{"multi.internal", "127.0.0.1", scan.StateOpen},
+		{"multi.internal", "127.0.0.2", scan.StateClosed},
+	}
+	if len(res) != len(expect) {
+		t.Fatalf("Expected %d results, got %d instead\n", len(expect), len(res))
+	}
+	for i, e := range expect {
+		if res[i].Host != e.host || res[i].Address != e.address {
+			t.Errorf("Expected %s (%s), got %s (%s) instead\n", e.host, e.address, res[i].Host, res[i].Address)
+		}
+		if len(res[i].PortStates) != 1 || res[i].PortStates[0].State != e.state {
+			t.Errorf("Expected port %s of %s to be %s, got %v instead\n", portStr, e.address, e.state, res[i].PortStates)
+		}
+	}
+}
@@ -1,180 +1,728 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"strconv"
-	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
+const (
+	// DefaultWorkers is the number of ports scanned in parallel on a single host
+	DefaultWorkers = 50
+	// DefaultHostParallelism is the number of hosts scanned in parallel
+	DefaultHostParallelism = 5
+	// DefaultTcpTimeout is the time to wait for a TCP connection
+	DefaultTcpTimeout = time.Second
+	// DefaultUdpTimeout is the time to wait for a UDP response
+	DefaultUdpTimeout = 200 * time.Millisecond
+	// DefaultBannerTimeout is the time to wait for a banner
+	DefaultBannerTimeout = 2 * time.Second
+)
+
 // TODO tidy up this file
 // try to improve the performance of the scans
 type PortState struct {
-	Port int
-	Open state
+	Port int `json:"port"`
+	// Protocol is the protocol used to scan the port, ProtocolTcp or ProtocolUdp
+	Protocol string `json:"protocol"`
+	State    State  `json:"state"`
+	// Attempts is the number of probes sent to the port
+	Attempts int `json:"attempts"`
+	// Banner is what an open TCP port sent after connecting,
+	// it is only set when ScanCfg.Banners is enabled
+	Banner string `json:"banner,omitempty"`
+	// Service is the name of the service that answered a UDP probe
+	Service string `json:"service,omitempty"`
+	// Err is the error of the last probe, if any,
+	// it explains why the port is not open
+	Err error `json:"error,omitempty"`
+	// Elapsed is the time spent scanning the port, retries included
+	Elapsed time.Duration `json:"elapsed_ns"`
 }
 
-type portScanner func(host string, port int) PortState
+const (
+	ProtocolTcp = "tcp"
+	ProtocolUdp = "udp"
+)
+
+var (
+	ErrNoProtocol    = errors.New("no protocol selected for the scan")
+	ErrInvalidConfig = errors.New("invalid scan configuration")
+)
+
+type portScanner func(ctx context.Context, host string, port int, cfg *ScanCfg) PortState
+
+// portJob is a single port to scan with one of the protocols
+type portJob struct {
+	port        int
+	scannerFunc portScanner
+}
 
 type ScanCfg struct {
+	// Ports are the ports scanned on every host
+	// except the hosts with their own ports in the HostInfo
 	Ports []string
 	Tcp   bool
 	Udp   bool
+	// Workers is the number of ports scanned in parallel on each host,
+	// DefaultWorkers is used if it's not set
+	Workers int
+	// HostParallelism is the number of hosts scanned in parallel,
+	// DefaultHostParallelism is used if it's not set
+	HostParallelism int
+	// TcpTimeout is the time to wait for a TCP connection,
+	// DefaultTcpTimeout is used if it's not set
+	TcpTimeout time.Duration
+	// UdpTimeout is the time to wait for a UDP response,
+	// DefaultUdpTimeout is used if it's not set
+	UdpTimeout time.Duration
+	// Retries is the number of times a port that timed out is probed again
+	Retries int
+	// RetryBackoff is the wait before the first retry,
+	// it doubles with every retry
+	RetryBackoff time.Duration
+	// Banners enables reading what open TCP ports send after connecting
+	Banners bool
+	// BannerTimeout is the time to wait for a banner,
+	// DefaultBannerTimeout is used if it's not set
+	BannerTimeout time.Duration
+	// BannerNudge sends a line break to ports that stay silent
+	// and waits for a banner once more
+	BannerNudge bool
+	// UdpProbes are the payloads sent to the UDP ports,
+	// DefaultProbes is used if it's not set
+	UdpProbes ProbeTable
+	// Logger receives the diagnostics of the scan, nothing is logged if it's not set
+	Logger Logger
+	// MaxRate is the maximum number of probes per second for the whole scan,
+	// there's no limit if it's not set
+	MaxRate float64
+	// HostRate is the maximum number of probes per second sent to a single host,
+	// there's no limit if it's not set
+	HostRate float64
+	// AllAddresses scans every address of a host separately
+	// instead of only the first one
+	AllAddresses bool
+	// SkipDiscovery scans the ports of all the hosts
+	// without checking first if they are up
+	SkipDiscovery bool
+	// DiscoveryPorts are the ports pinged to check if a host is up,
+	// DefaultDiscoveryPorts is used if it's not set
+	DiscoveryPorts []string
+	// Resolver resolves the host names,
+	// the system resolver is used if it's not set
+	Resolver Resolver
+	// IPVersion restricts the scan to IPv4 with 4 or IPv6 with 6,
+	// both are scanned if it's not set
+	IPVersion int
+	// MaxTargets is the maximum number of hosts a CIDR prefix
+	// or range of IPs can expand to, DefaultMaxTargets is used if it's not set
+	MaxTargets int
+
+	// limiter and hostLimiter are shared by all the hosts
+	// and by the ports of a host respectively
+	limiter     *limiter
+	hostLimiter *limiter
 }
 
-type state bool
+// Validate checks the configuration and the ports, RunContext calls it
+// but it's useful to check the configuration before preparing a scan
+func (cfg *ScanCfg) Validate() error {
+	if cfg.Workers < 0 {
+		return fmt.Errorf("%w: workers can't be negative: %d", ErrInvalidConfig, cfg.Workers)
+	}
+	if cfg.HostParallelism < 0 {
+		return fmt.Errorf("%w: host parallelism can't be negative: %d", ErrInvalidConfig, cfg.HostParallelism)
+	}
+	if cfg.TcpTimeout < 0 || cfg.UdpTimeout < 0 || cfg.BannerTimeout < 0 {
+		return fmt.Errorf("%w: timeouts can't be negative", ErrInvalidConfig)
+	}
+	if cfg.Retries < 0 {
+		return fmt.Errorf("%w: retries can't be negative: %d", ErrInvalidConfig, cfg.Retries)
+	}
+	if cfg.IPVersion != 0 && cfg.IPVersion != 4 && cfg.IPVersion != 6 {
+		return fmt.Errorf("%w: IP version has to be 4 or 6: %d", ErrInvalidConfig, cfg.IPVersion)
+	}
+	if cfg.MaxTargets < 0 {
+		return fmt.Errorf("%w: max targets can't be negative: %d", ErrInvalidConfig, cfg.MaxTargets)
+	}
+	if cfg.MaxRate < 0 || cfg.HostRate < 0 {
+		return fmt.Errorf("%w: rates can't be negative", ErrInvalidConfig)
+	}
+	if cfg.RetryBackoff < 0 {
+		return fmt.Errorf("%w: retry backoff can't be negative: %s", ErrInvalidConfig, cfg.RetryBackoff)
+	}
+
+	ports, err := ParsePorts(cfg.Ports)
+	if err != nil {
+		return err
+	}
+	if !cfg.Tcp && !cfg.Udp && len(ports) > 0 {
+		return ErrNoProtocol
+	}
+	if _, err := ParsePorts(cfg.DiscoveryPorts); err != nil {
+		return fmt.Errorf("discovery ports: %w", err)
+	}
+
+	return nil
+}
+
+// withDefaults returns a copy of the configuration
+// with the defaults set for the fields that are not set
+func (cfg *ScanCfg) withDefaults() *ScanCfg {
+	c := *cfg
+	if c.Workers < 1 {
+		c.Workers = DefaultWorkers
+	}
+	if c.HostParallelism < 1 {
+		c.HostParallelism = DefaultHostParallelism
+	}
+	if c.TcpTimeout == 0 {
+		c.TcpTimeout = DefaultTcpTimeout
+	}
+	if c.UdpTimeout == 0 {
+		c.UdpTimeout = DefaultUdpTimeout
+	}
+	if c.BannerTimeout == 0 {
+		c.BannerTimeout = DefaultBannerTimeout
+	}
+	if len(c.DiscoveryPorts) == 0 {
+		c.DiscoveryPorts = DefaultDiscoveryPorts
+	}
+	if c.Resolver == nil {
+		c.Resolver = net.DefaultResolver
+	}
+	if c.MaxTargets == 0 {
+		c.MaxTargets = DefaultMaxTargets
+	}
+	if c.UdpProbes == nil {
+		c.UdpProbes = DefaultProbes()
+	}
+
+	return &c
+}
+
+// State is the state of a scanned port
+type State int
+
+const (
+	// StateClosed means the host refused the connection
+	StateClosed State = iota
+	// StateOpen means the port accepted the connection or answered the probe
+	StateOpen
+	// StateFiltered means the probe got no answer or the host was unreachable,
+	// usually because of a firewall dropping the packets
+	StateFiltered
+	// StateOpenFiltered means a UDP probe got no answer,
+	// the port is either open or filtered
+	StateOpenFiltered
+)
 
 type Results struct {
-	Host       string
-	NotFound   bool
-	PortStates []PortState
+	Host string `json:"host"`
+	// Addresses are the addresses the host resolved to
+	Addresses []string `json:"addresses"`
+	// Address is the address that was scanned when
+	// every address of the host is scanned separately,
+	// otherwise the ports are scanned on Addresses in order
+	// until they are open, like net.Dialer does
+	Address  string `json:"address,omitempty"`
+	NotFound bool   `json:"not_found"`
+	// Status tells if the host is up, it's unknown when the discovery is skipped
+	Status HostStatus `json:"status"`
+	// Pings is the number of discovery probes sent to the host
+	Pings int `json:"pings"`
+	// Err is the error looking up the host when it's not found
+	Err error `json:"error,omitempty"`
+	// Start is when the scan of the host started
+	Start time.Time `json:"start"`
+	// Elapsed is the time spent scanning the host
+	Elapsed    time.Duration `json:"elapsed_ns"`
+	PortStates []PortState   `json:"ports"`
 }
 
 // implement the Stringer interface
-func (s state) String() string {
-	if s {
+func (s State) String() string {
+	switch s {
+	case StateOpen:
 		return "open"
+	case StateFiltered:
+		return "filtered"
+	case StateOpenFiltered:
+		return "open|filtered"
+	default:
+		return "closed"
 	}
-	return "closed"
 }
 
-func Run(hl *HostsList, cfg *ScanCfg) []Results {
+// Run scans the ports from cfg on every host in the list
+// and waits for all the hosts to be scanned
+// the results keep the order of the hosts list and of the ports from cfg
+func Run(hl *HostsList, cfg *ScanCfg) ([]Results, error) {
+	resCh, err := RunContext(context.Background(), hl, cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	res := make([]Results, 0, len(hl.Hosts))
-	var scannerFunc portScanner
+	for r := range resCh {
+		res = append(res, r)
+	}
+
+	return res, nil
+}
+
+// RunContext starts scanning the hosts in the list and sends
+// the results of every host on the returned channel, in the order of the hosts list.
+// CIDR prefixes and ranges of IPs in the list are expanded while scanning,
+// with one result for every address, the entries that can't be parsed
+// get a result not found with ErrInvalidTarget and the ones bigger
+// than MaxTargets with ErrTooManyTargets.
+// With AllAddresses every resolved address of a host gets its own result.
+// Unless SkipDiscovery is enabled the hosts are pinged first
+// and the ports of the hosts that are down are not scanned.
+// Hosts and ports are scanned concurrently.
+// When ctx is done no other host is started, the outstanding dials are
+// aborted and the hosts already started are sent with the ports scanned so far.
+// The channel is closed once all the started hosts are sent
+// so callers should read it until it's closed.
+func RunContext(ctx context.Context, hl *HostsList, cfg *ScanCfg) (<-chan Results, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var scanners []portScanner
 	if cfg.Tcp {
-		scannerFunc = scanTcpPort
+		scanners = append(scanners, scanTcpPort)
 	}
 
 	if cfg.Udp {
-		scannerFunc = scanUdpPort
+		scanners = append(scanners, scanUdpPort)
 	}
 
+	ports, err := ParsePorts(cfg.Ports)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := buildJobs(scanners, ports)
+
+	cfg = cfg.withDefaults()
+	discoveryPorts, err := ParsePorts(cfg.DiscoveryPorts)
+	if err != nil {
+		return nil, fmt.Errorf("discovery ports: %w", err)
+	}
+	cfg.limiter = newLimiter(cfg.MaxRate)
+	cfg.Resolver = newCachingResolver(cfg.Resolver)
+	hostParallelism := cfg.HostParallelism
+
+	// the entries are only parsed here, they are expanded while scanning
+	targets := make([]*target, 0, len(hl.Hosts))
+	// the hosts with their own ports get their own jobs
+	targetJobs := make([][]portJob, 0, len(hl.Hosts))
 	for _, h := range hl.Hosts {
-		r := Results{
-			Host: h,
+		t, err := parseTarget(h)
+		if err != nil {
+			// a hosts file edited by hand can have invalid entries
+			targets = append(targets, &target{entry: h, err: err})
+			targetJobs = append(targetJobs, nil)
+			continue
 		}
-		// do the host checkup and see if it exists
-		if _, err := net.LookupHost(h); err != nil {
-			r.NotFound = true
-			res = append(res, r)
+		if t.size > uint64(cfg.MaxTargets) {
+			err := fmt.Errorf("%w: %s has %d hosts, the maximum is %d",
+				ErrTooManyTargets, h, t.size, cfg.MaxTargets)
+			targets = append(targets, &target{entry: h, err: err})
+			targetJobs = append(targetJobs, nil)
 			continue
 		}
+		targets = append(targets, t)
 
-		for _, p := range cfg.Ports {
-			if !checkIfInterval(p) {
-				intPort, err := strconv.Atoi(p)
-				if err != nil {
-					fmt.Println("Error converting port:", p)
-					continue
-				}
-				if !isPortValid(intPort) {
-					fmt.Println("port is not valid: ", intPort)
-					continue
+		tJobs := jobs
+		if spec := hl.Info[h].Ports; spec != "" {
+			hostPorts, err := ParsePorts([]string{spec})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", h, err)
+			}
+			tJobs = buildJobs(scanners, hostPorts)
+		}
+		targetJobs = append(targetJobs, tJobs)
+	}
+
+	// every started host gets its own channel
+	// and the channels are queued in the order of the hosts
+	// so the results can be sent in order while hosts finish in any order
+	queue := make(chan chan []Results, hostParallelism)
+	go func() {
+		defer close(queue)
+		sem := make(chan struct{}, hostParallelism)
+		for i, t := range targets {
+			if t.err != nil {
+				if ctx.Err() != nil {
+					return
 				}
-				r.PortStates = append(r.PortStates, scannerFunc(h, intPort))
+				// the entry is never scanned so the reason is always shown
+				cfg.logf(LogInfo, "%s: skipped: %v", t.entry, t.err)
+				hostRes := make(chan []Results, 1)
+				hostRes <- []Results{{Host: t.entry, NotFound: true, Err: t.err, Start: time.Now()}}
+				queue <- hostRes
 				continue
 			}
-			intervalPorts, err := processIntervalPorts(p)
-			if err != nil {
-				fmt.Println("interval is invalid: ", p, err)
-				continue
+
+			jobs := targetJobs[i]
+			next := t.hosts()
+			for h, ok := next(); ok; h, ok = next() {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				hostRes := make(chan []Results, 1)
+				queue <- hostRes
+				go func(h string) {
+					hostRes <- scanHost(ctx, h, jobs, discoveryPorts, cfg)
+					<-sem
+				}(h)
 			}
-			for i := intervalPorts[0]; i <= intervalPorts[len(intervalPorts)-1]; i++ {
-				r.PortStates = append(r.PortStates, scannerFunc(h, i))
+		}
+	}()
+
+	resCh := make(chan Results)
+	go func() {
+		defer close(resCh)
+		for hostRes := range queue {
+			for _, r := range <-hostRes {
+				resCh <- r
 			}
 		}
+	}()
 
-		res = append(res, r)
-	}
-	return res
+	return resCh, nil
 }
 
-func checkIfInterval(port string) bool {
-	// check if it's an interval of ports
-	return strings.Contains(port, "-")
+// stateRank orders the states by how much they tell about the port
+var stateRank = map[State]int{StateFiltered: 0, StateOpenFiltered: 1, StateClosed: 2, StateOpen: 3}
+
+// scanFallback scans the port on the addresses in order until it's open,
+// like net.Dialer falls back to the next address of a host,
+// the state that tells the most is returned with the attempts of all the addresses
+func scanFallback(ctx context.Context, job portJob, addrs []string, cfg *ScanCfg) PortState {
+	var best PortState
+	attempts := 0
+	for i, a := range addrs {
+		ps := job.scannerFunc(ctx, a, job.port, cfg)
+		attempts += ps.Attempts
+		if i == 0 || stateRank[ps.State] > stateRank[best.State] {
+			best = ps
+		}
+		if ps.State == StateOpen || ctx.Err() != nil {
+			break
+		}
+	}
+	best.Attempts = attempts
+
+	return best
 }
 
-func isPortValid(port int) bool {
-	if port < 1 || port > 65535 {
-		return false
+// buildJobs returns the jobs scanning the ports with every scanner,
+// the TCP ports are scanned first and then the UDP ones
+func buildJobs(scanners []portScanner, ports []int) []portJob {
+	jobs := make([]portJob, 0, len(scanners)*len(ports))
+	for _, scannerFunc := range scanners {
+		for _, p := range ports {
+			jobs = append(jobs, portJob{port: p, scannerFunc: scannerFunc})
+		}
 	}
 
-	return true
+	return jobs
 }
 
-func processIntervalPorts(intervalPorts string) ([]int, error) {
-	ports := strings.Split(intervalPorts, "-")
+// scanHost resolves a host and scans its ports,
+// once for every address when AllAddresses is enabled
+func scanHost(ctx context.Context, host string, jobs []portJob, discoveryPorts []int, cfg *ScanCfg) []Results {
+	r := Results{
+		Host:  host,
+		Start: time.Now(),
+	}
+	// do the host checkup and see if it exists
+	addrs, err := lookupHost(ctx, cfg.Resolver, host, cfg.IPVersion)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.NotFound = true
+			r.Err = err
+			cfg.logf(LogVerbose, "%s: host not found: %v", host, err)
+		}
+		r.Elapsed = time.Since(r.Start)
+		return []Results{r}
+	}
+	r.Addresses = addrs
 
-	intPorts := make([]int, 0, len(ports))
-	for _, p := range ports {
-		intPort, err := strconv.Atoi(p)
-		if err != nil {
-			return nil, fmt.Errorf("error converting port to int %s", p)
+	if !cfg.AllAddresses {
+		r = scanPorts(ctx, r, addrs, jobs, discoveryPorts, cfg)
+		r.Elapsed = time.Since(r.Start)
+		return []Results{r}
+	}
+
+	res := make([]Results, 0, len(addrs))
+	for i, a := range addrs {
+		if ctx.Err() != nil {
+			break
 		}
-		if !isPortValid(intPort) {
-			return nil, fmt.Errorf("port is out of range %s", p)
+		ar := r
+		ar.Address = a
+		// the first address is timed from the lookup
+		if i > 0 {
+			ar.Start = time.Now()
 		}
-		intPorts = append(intPorts, intPort)
+		ar = scanPorts(ctx, ar, []string{a}, jobs, discoveryPorts, cfg)
+		ar.Elapsed = time.Since(ar.Start)
+		res = append(res, ar)
 	}
 
-	return intPorts, nil
+	return res
 }
 
-// send a packet and check if you get an error back
-// if no error gets back then the port is open
-// if an error is sent back then the port is closed
-func scanUdpPort(host string, port int) PortState {
-	p := PortState{
-		Open: false,
-		Port: port,
+// scanPorts scans the ports of the addresses of a host using a pool of workers
+// every worker writes in its own slot so the order of the ports is kept.
+// The addresses are discovered in order and the scan starts from the first one up,
+// the ports are not scanned if the discovery finds all the addresses down
+func scanPorts(ctx context.Context, r Results, addrs []string, jobs []portJob, discoveryPorts []int, cfg *ScanCfg) Results {
+	// the discovery pings count for the host rate too
+	hostCfg := *cfg
+	hostCfg.hostLimiter = newLimiter(cfg.HostRate)
+	cfg = &hostCfg
+
+	if !cfg.SkipDiscovery {
+		r.Status = HostDown
+		for i, a := range addrs {
+			status, pings := discover(ctx, a, discoveryPorts, cfg)
+			r.Pings += pings
+			if status == HostUp {
+				r.Status = HostUp
+				addrs = addrs[i:]
+				break
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		if r.Status == HostDown || ctx.Err() != nil {
+			return r
+		}
 	}
-	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-	adr, err := net.ResolveUDPAddr("udp", address)
-	if err != nil {
-		return p
+	address := addrs[0]
+
+	if len(jobs) == 0 {
+		return r
 	}
 
-	con, err := net.DialUDP("udp", nil, adr)
-	if err != nil {
-		return p
+	cfg.logf(LogVerbose, "%s: scanning %d ports", address, len(jobs))
+
+	portStates := make([]PortState, len(jobs))
+	// ports aborted by the context are not reported
+	scanned := make([]bool, len(jobs))
+	jobsCh := make(chan int)
+	var wg sync.WaitGroup
+	workers := cfg.Workers
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobsCh {
+				start := time.Now()
+				portStates[i] = scanFallback(ctx, jobs[i], addrs, cfg)
+				portStates[i].Elapsed = time.Since(start)
+				scanned[i] = ctx.Err() == nil
+			}
+		}()
 	}
-	defer con.Close()
 
-	packet := []byte("c")
-	_, err = con.Write(packet)
-	if err != nil {
-		return p
+	for i := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		jobsCh <- i
 	}
+	close(jobsCh)
+	wg.Wait()
 
-	resp := make([]byte, 1024)
-	con.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
-	n, _, err := con.ReadFromUDP(resp)
-	if err != nil {
-		fmt.Println("Err: ", fmt.Sprintf("Err:, %v", err))
-		return p
+	for i, ps := range portStates {
+		if scanned[i] {
+			r.PortStates = append(r.PortStates, ps)
+		}
 	}
 
-	fmt.Printf("Response: %s\n", resp[:n])
+	cfg.logf(LogVerbose, "%s: done", address)
+	return r
+}
+
+// withRetries calls probe until it's done or the retries run out,
+// waiting for the rate limits before every attempt
+// and for the backoff between the attempts
+// it returns the number of attempts
+func withRetries(ctx context.Context, cfg *ScanCfg, probe func() (done bool)) int {
+	backoff := cfg.RetryBackoff
+	attempts := 0
+	for {
+		if cfg.limiter.wait(ctx) != nil || cfg.hostLimiter.wait(ctx) != nil {
+			return attempts
+		}
+
+		attempts++
+		if probe() || attempts > cfg.Retries {
+			return attempts
+		}
+
+		if backoff > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return attempts
+			}
+			backoff *= 2
+		}
+		if ctx.Err() != nil {
+			return attempts
+		}
+	}
+}
+
+// unblockOnDone unblocks the reads on the connection as soon as ctx is done,
+// the returned function has to be called once the connection is not used anymore
+func unblockOnDone(ctx context.Context, con interface{ SetReadDeadline(time.Time) error }) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			con.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	return func() { close(stop) }
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isRefused checks if the host answered that nothing listens on the port,
+// a TCP reset or an ICMP port unreachable for UDP
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// errorState derives the state of a port from the error of a probe
+// silent drops and unreachable hosts are considered filtered
+func errorState(err error) State {
+	if isRefused(err) {
+		return StateClosed
+	}
+
+	return StateFiltered
+}
+
+// send the probe of the port and check what gets back
+// if a response gets back then the port is open
+// if an ICMP port unreachable gets back then the port is closed
+// if nothing gets back then the port is open or filtered
+func scanUdpPort(ctx context.Context, host string, port int, cfg *ScanCfg) PortState {
+	p := PortState{
+		Port:     port,
+		Protocol: ProtocolUdp,
+	}
+	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+
+	p.Attempts = withRetries(ctx, cfg, func() bool {
+		var d net.Dialer
+		con, err := d.DialContext(ctx, cfg.network(ProtocolUdp), address)
+		if err != nil {
+			p.State = StateFiltered
+			p.Err = err
+			return true
+		}
+		defer con.Close()
+
+		defer unblockOnDone(ctx, con)()
+
+		probe := cfg.UdpProbes.probe(port)
+		_, err = con.Write(probe.Payload)
+		if err != nil {
+			p.State = errorState(err)
+			p.Err = err
+			return true
+		}
+
+		resp := make([]byte, 1024)
+		con.SetReadDeadline(time.Now().Add(cfg.UdpTimeout))
+		n, err := con.Read(resp)
+		if err != nil {
+			cfg.logf(LogDebug, "%s/udp: %v", address, err)
+			p.Err = err
+			if isTimeout(err) {
+				// no response might be a lost packet so try again
+				p.State = StateOpenFiltered
+				return false
+			}
+			p.State = errorState(err)
+			return true
+		}
+
+		cfg.logf(LogDebug, "%s/udp: response: %q", address, resp[:n])
+		p.Err = nil
+
+		// any response means something listens on the port,
+		// a response matching the probe tells which service it is
+		p.State = StateOpen
+		if probe.Name != "" && (probe.Match == nil || probe.Match(resp[:n])) {
+			p.Service = probe.Name
+		}
+		return true
+	})
 
-	p.Open = true
 	return p
 }
 
-func scanTcpPort(host string, port int) PortState {
+func scanTcpPort(ctx context.Context, host string, port int, cfg *ScanCfg) PortState {
 	p := PortState{
-		Port: port,
+		Port:     port,
+		Protocol: ProtocolTcp,
 	}
 
 	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-	// do the network connection attempt
-	scanConn, err := net.DialTimeout("tcp", address, time.Second*1)
-	if err != nil {
-		// assume the port is closed
-		return p
-	}
+	p.Attempts = withRetries(ctx, cfg, func() bool {
+		// do the network connection attempt
+		d := net.Dialer{Timeout: cfg.TcpTimeout}
+		scanConn, err := d.DialContext(ctx, cfg.network(ProtocolTcp), address)
+		if err != nil {
+			cfg.logf(LogDebug, "%s/tcp: %v", address, err)
+			p.State = errorState(err)
+			p.Err = err
+			// the connection timing out might be a lost packet so try again
+			return !isTimeout(err)
+		}
+
+		defer scanConn.Close()
+		p.State = StateOpen
+		p.Err = nil
+		if cfg.Banners {
+			p.Banner = grabBanner(ctx, scanConn, cfg)
+		}
+		return true
+	})
 
-	scanConn.Close()
-	p.Open = true
 	return p
 }
@@ -1,9 +1,16 @@
 package scan_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"net"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/Serares/pscanner/scan"
 )
@@ -11,14 +18,24 @@ import (
 func TestStateString(t *testing.T) {
 	ps := scan.PortState{}
 
-	if ps.Open.String() != "closed" {
-		t.Errorf("expected %q, got %q instead\n", "closed", ps.Open.String())
+	if ps.State.String() != "closed" {
+		t.Errorf("expected %q, got %q instead\n", "closed", ps.State.String())
 	}
 
-	ps.Open = true
-
-	if ps.Open.String() != "open" {
-		t.Errorf("Expected %q, got %q instead\n", "open", ps.Open.String())
+	testCases := []struct {
+		state  scan.State
+		expect string
+	}{
+		{scan.StateClosed, "closed"},
+		{scan.StateOpen, "open"},
+		{scan.StateFiltered, "filtered"},
+		{scan.StateOpenFiltered, "open|filtered"},
+	}
+	for _, tc := range testCases {
+		ps.State = tc.state
+		if ps.State.String() != tc.expect {
+			t.Errorf("Expected %q, got %q instead\n", tc.expect, ps.State.String())
+		}
 	}
 }
 
@@ -56,7 +73,10 @@ func TestRunHostsFound(t *testing.T) {
 		}
 	}
 
-	res := scan.Run(hl, &scan.ScanCfg{Ports: ports, Tcp: true})
+	res, err := scan.Run(hl, &scan.ScanCfg{Ports: ports, Tcp: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
 	if len(res) != 1 {
 		t.Fatalf("Expected 1 results, got %d instead\n", len(res))
 	}
@@ -70,17 +90,28 @@ func TestRunHostsFound(t *testing.T) {
 	if len(res[0].PortStates) != 2 {
 		t.Fatalf("Expected 2 port states, got %d instead\n", len(res[0].PortStates))
 	}
+	// the port states are sorted by port number
+	if res[0].PortStates[0].Port > res[0].PortStates[1].Port {
+		t.Errorf("Expected port states sorted by port, got %d before %d\n",
+			res[0].PortStates[0].Port, res[0].PortStates[1].Port)
+	}
 	for i, tc := range testCases {
 		portToCheck, err := strconv.Atoi(ports[i])
 		if err != nil {
 			t.Fatalf("error converting port to int %v", ports[i])
 		}
-		if res[0].PortStates[i].Port != portToCheck {
-			t.Errorf("Expected port %s, got %d instead\n", ports[0],
-				res[0].PortStates[i].Port)
+		found := false
+		for _, ps := range res[0].PortStates {
+			if ps.Port != portToCheck {
+				continue
+			}
+			found = true
+			if ps.State.String() != tc.expectState {
+				t.Errorf("Expected port %s to be %s\n", ports[i], tc.expectState)
+			}
 		}
-		if res[0].PortStates[i].Open.String() != tc.expectState {
-			t.Errorf("Expected port %s to be %s\n", ports[i], tc.expectState)
+		if !found {
+			t.Errorf("Expected port %s in the results\n", ports[i])
 		}
 	}
 }
@@ -89,7 +120,10 @@ func TestRunHostNotFound(t *testing.T) {
 	host := "389.389.389.389"
 	hl := &scan.HostsList{}
 	hl.Add(host)
-	res := scan.Run(hl, &scan.ScanCfg{Ports: []string{}, Tcp: true})
+	res, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{}, Tcp: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
 	// Verify results for HostNotFound test
 	if len(res) != 1 {
 		t.Fatalf("Expected 1 results, got %d instead\n", len(res))
@@ -103,4 +137,373 @@ func TestRunHostNotFound(t *testing.T) {
 	if len(res[0].PortStates) != 0 {
 		t.Fatalf("Expected 0 port states, got %d instead\n", len(res[0].PortStates))
 	}
+	if res[0].Err == nil {
+		t.Errorf("Expected the lookup error for host %q\n", host)
+	}
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  *scan.ScanCfg
+	}{
+		{"NegativeWorkers", &scan.ScanCfg{Tcp: true, Workers: -1}},
+		{"NegativeHostParallelism", &scan.ScanCfg{Tcp: true, HostParallelism: -1}},
+		{"NegativeTimeout", &scan.ScanCfg{Tcp: true, TcpTimeout: -time.Second}},
+		{"NegativeRetries", &scan.ScanCfg{Tcp: true, Retries: -1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			hl.Add("localhost")
+
+			_, err := scan.Run(hl, tc.cfg)
+			if !errors.Is(err, scan.ErrInvalidConfig) {
+				t.Errorf("Expected error %q, got %q instead\n", scan.ErrInvalidConfig, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cfg       *scan.ScanCfg
+		expectErr error
+	}{
+		{"Valid", &scan.ScanCfg{Tcp: true, Ports: []string{"22-443"}}, nil},
+		{"NegativeWorkers", &scan.ScanCfg{Tcp: true, Workers: -1}, scan.ErrInvalidConfig},
+		{"InvalidRange", &scan.ScanCfg{Tcp: true, Ports: []string{"443-22"}}, scan.ErrInvalidRange},
+		{"NoProtocol", &scan.ScanCfg{Ports: []string{"22"}}, scan.ErrNoProtocol},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.Validate(); !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected error %v, got %v instead\n", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestRunPortErrors(t *testing.T) {
+	host := "localhost"
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	var logs bytes.Buffer
+	cfg := &scan.ScanCfg{
+		Ports:  []string{portStr},
+		Tcp:    true,
+		Logger: scan.NewLogger(&logs, scan.LogDebug),
+	}
+	res, err := scan.Run(hl, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if len(res) != 1 || len(res[0].PortStates) != 1 {
+		t.Fatalf("Expected 1 port state, got %v instead\n", res)
+	}
+	if !errors.Is(res[0].PortStates[0].Err, syscall.ECONNREFUSED) {
+		t.Errorf("Expected error %q, got %q instead\n", syscall.ECONNREFUSED, res[0].PortStates[0].Err)
+	}
+	if !strings.Contains(logs.String(), portStr) {
+		t.Errorf("Expected the port %s in the debug logs, got %q instead\n", portStr, logs.String())
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var out bytes.Buffer
+	l := scan.NewLogger(&out, scan.LogVerbose)
+
+	l.Logf(scan.LogInfo, "info %d", 1)
+	l.Logf(scan.LogVerbose, "verbose %d", 2)
+	l.Logf(scan.LogDebug, "debug %d", 3)
+
+	expect := "info 1\nverbose 2\n"
+	if out.String() != expect {
+		t.Errorf("Expected logs %q, got %q instead\n", expect, out.String())
+	}
+}
+
+func TestRunKeepsOrder(t *testing.T) {
+	hosts := []string{"localhost", "127.0.0.1", "unknownhostoutthere"}
+	hl := &scan.HostsList{}
+	for _, h := range hosts {
+		hl.Add(h)
+	}
+
+	ports := []string{}
+	for i := 0; i < 10; i++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		_, portStr, err := net.SplitHostPort(ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ports = append(ports, portStr)
+	}
+	// the results come sorted by port number
+	sort.Slice(ports, func(i, j int) bool {
+		pi, _ := strconv.Atoi(ports[i])
+		pj, _ := strconv.Atoi(ports[j])
+		return pi < pj
+	})
+
+	res, err := scan.Run(hl, &scan.ScanCfg{Ports: ports, Tcp: true, Workers: 3, HostParallelism: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if len(res) != len(hl.Hosts) {
+		t.Fatalf("Expected %d results, got %d instead\n", len(hl.Hosts), len(res))
+	}
+
+	for i, r := range res {
+		if r.Host != hl.Hosts[i] {
+			t.Errorf("Expected host %q at index %d, got %q instead\n", hl.Hosts[i], i, r.Host)
+		}
+		if r.NotFound {
+			continue
+		}
+		if len(r.PortStates) != len(ports) {
+			t.Fatalf("Expected %d port states, got %d instead\n", len(ports), len(r.PortStates))
+		}
+		for j, p := range r.PortStates {
+			if strconv.Itoa(p.Port) != ports[j] {
+				t.Errorf("Expected port %s at index %d, got %d instead\n", ports[j], j, p.Port)
+			}
+			if p.State.String() != "open" {
+				t.Errorf("Expected port %d to be open\n", p.Port)
+			}
+		}
+	}
+}
+
+func TestRunNoProtocol(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	_, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{"22"}})
+	if !errors.Is(err, scan.ErrNoProtocol) {
+		t.Errorf("Expected error %q, got %q instead\n", scan.ErrNoProtocol, err)
+	}
+}
+
+func TestRunContextCancel(t *testing.T) {
+	hl := &scan.HostsList{}
+	for _, h := range []string{"localhost", "127.0.0.1"} {
+		hl.Add(h)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resCh, err := scan.RunContext(ctx, hl, &scan.ScanCfg{Ports: []string{"1-65535"}, Tcp: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	for r := range resCh {
+		if len(r.PortStates) != 0 {
+			t.Errorf("Expected no port states for host %q after cancel, got %d instead\n",
+				r.Host, len(r.PortStates))
+		}
+	}
+}
+
+func TestRunContextStream(t *testing.T) {
+	hl := &scan.HostsList{}
+	for _, h := range []string{"localhost", "127.0.0.1", "unknownhostoutthere"} {
+		hl.Add(h)
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resCh, err := scan.RunContext(context.Background(), hl,
+		&scan.ScanCfg{Ports: []string{portStr}, Tcp: true, HostParallelism: 3})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+
+	i := 0
+	for r := range resCh {
+		if r.Host != hl.Hosts[i] {
+			t.Errorf("Expected host %q at index %d, got %q instead\n", hl.Hosts[i], i, r.Host)
+		}
+		i++
+	}
+	if i != len(hl.Hosts) {
+		t.Errorf("Expected %d results, got %d instead\n", len(hl.Hosts), i)
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	host := "localhost"
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	// a UDP listener that never answers makes every probe time out
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, portStr, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &scan.ScanCfg{
+		Ports:        []string{portStr},
+		Udp:          true,
+		UdpTimeout:   10 * time.Millisecond,
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	}
+	res, err := scan.Run(hl, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if len(res) != 1 || len(res[0].PortStates) != 1 {
+		t.Fatalf("Expected 1 port state, got %v instead\n", res)
+	}
+	if res[0].PortStates[0].Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d instead\n", res[0].PortStates[0].Attempts)
+	}
+	if res[0].PortStates[0].State != scan.StateOpenFiltered {
+		t.Errorf("Expected state %q, got %q instead\n",
+			scan.StateOpenFiltered, res[0].PortStates[0].State)
+	}
+}
+
+func TestRunUdpClosed(t *testing.T) {
+	host := "localhost"
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	// a port that was just released answers with ICMP port unreachable
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, portStr, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	res, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{portStr}, Udp: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if len(res) != 1 || len(res[0].PortStates) != 1 {
+		t.Fatalf("Expected 1 port state, got %v instead\n", res)
+	}
+	if res[0].PortStates[0].State != scan.StateClosed {
+		t.Errorf("Expected state %q, got %q instead\n",
+			scan.StateClosed, res[0].PortStates[0].State)
+	}
+}
+
+func TestRunTcpAndUdp(t *testing.T) {
+	host := "localhost"
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{portStr}, Tcp: true, Udp: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if len(res) != 1 || len(res[0].PortStates) != 2 {
+		t.Fatalf("Expected 2 port states, got %v instead\n", res)
+	}
+
+	expectProtocols := []string{scan.ProtocolTcp, scan.ProtocolUdp}
+	for i, p := range res[0].PortStates {
+		if p.Protocol != expectProtocols[i] {
+			t.Errorf("Expected protocol %q at index %d, got %q instead\n", expectProtocols[i], i, p.Protocol)
+		}
+		if strconv.Itoa(p.Port) != portStr {
+			t.Errorf("Expected port %s, got %d instead\n", portStr, p.Port)
+		}
+	}
+	if res[0].PortStates[0].State != scan.StateOpen {
+		t.Errorf("Expected TCP port %s to be open\n", portStr)
+	}
+}
+
+func TestRunHostPorts(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+	hl.Add("127.0.0.1")
+
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only 127.0.0.1 is scanned on the port of the listener
+	if err := hl.SetInfo("127.0.0.1", scan.HostInfo{Ports: portStr}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := scan.Run(hl, &scan.ScanCfg{Ports: []string{"1", "2"}, Tcp: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %q instead\n", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("Expected 2 results, got %d instead\n", len(res))
+	}
+
+	for _, r := range res {
+		switch r.Host {
+		case "127.0.0.1":
+			if len(r.PortStates) != 1 || strconv.Itoa(r.PortStates[0].Port) != portStr {
+				t.Errorf("Expected only port %s, got %v instead\n", portStr, r.PortStates)
+			} else if r.PortStates[0].State != scan.StateOpen {
+				t.Errorf("Expected port %s to be open\n", portStr)
+			}
+		case "localhost":
+			if len(r.PortStates) != 2 {
+				t.Errorf("Expected ports 1 and 2, got %v instead\n", r.PortStates)
+			}
+		}
+	}
 }
@@ -0,0 +1,140 @@
+package scan
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// servicesFile is the file that maps service names to ports
+const servicesFile = "/etc/services"
+
+// fallbackServices covers common names missing from the services file
+// or all of them when the file is not there
+var fallbackServices = map[string]int{
+	"ftp":           21,
+	"ssh":           22,
+	"telnet":        23,
+	"smtp":          25,
+	"domain":        53,
+	"dns":           53,
+	"http":          80,
+	"pop3":          110,
+	"ntp":           123,
+	"imap":          143,
+	"snmp":          161,
+	"ldap":          389,
+	"https":         443,
+	"microsoft-ds":  445,
+	"imaps":         993,
+	"pop3s":         995,
+	"mysql":         3306,
+	"ms-wbt-server": 3389,
+	"postgresql":    5432,
+}
+
+var (
+	servicesOnce sync.Once
+	services     map[string]int
+	// serviceNames maps port/protocol like 22/tcp to the service name
+	serviceNames map[string]string
+)
+
+// loadServices reads the services file once
+func loadServices() {
+	servicesOnce.Do(func() {
+		services, serviceNames = map[string]int{}, map[string]string{}
+		if f, err := os.Open(servicesFile); err == nil {
+			services, serviceNames = parseServices(f)
+			f.Close()
+		}
+
+		// sorted so that the first of the names of a port wins
+		names := make([]string, 0, len(fallbackServices))
+		for name := range fallbackServices {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			port := fallbackServices[name]
+			if _, ok := services[name]; !ok {
+				services[name] = port
+			}
+			for _, protocol := range []string{ProtocolTcp, ProtocolUdp} {
+				key := strconv.Itoa(port) + "/" + protocol
+				if _, ok := serviceNames[key]; !ok {
+					serviceNames[key] = name
+				}
+			}
+		}
+	})
+}
+
+// lookupService returns the port of a service name,
+// the names and aliases from the services file are case insensitive
+func lookupService(name string) (int, bool) {
+	loadServices()
+
+	port, ok := services[strings.ToLower(name)]
+	return port, ok
+}
+
+// ServiceName returns the name of the service usually on the port,
+// empty if the port has no known service
+func ServiceName(port int, protocol string) string {
+	loadServices()
+
+	return serviceNames[strconv.Itoa(port)+"/"+strings.ToLower(protocol)]
+}
+
+// parseServices reads lines in the format of /etc/services
+//
+//	name port/protocol [aliases...] [# comment]
+//
+// and returns the ports of the names and aliases
+// and the names of the port/protocol pairs
+func parseServices(r io.Reader) (map[string]int, map[string]string) {
+	s := map[string]int{}
+	portNames := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		portProto := strings.SplitN(fields[1], "/", 2)
+		port, err := strconv.Atoi(portProto[0])
+		if err != nil || !isPortValid(port) {
+			continue
+		}
+
+		if len(portProto) == 2 {
+			key := strconv.Itoa(port) + "/" + strings.ToLower(portProto[1])
+			if _, ok := portNames[key]; !ok {
+				portNames[key] = fields[0]
+			}
+		}
+
+		names := append([]string{fields[0]}, fields[2:]...)
+		for _, n := range names {
+			n = strings.ToLower(n)
+			// keep the first entry, tcp usually comes before udp
+			if _, ok := s[n]; !ok {
+				s[n] = port
+			}
+		}
+	}
+
+	return s, portNames
+}
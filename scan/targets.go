@@ -0,0 +1,193 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxTargets is the maximum number of hosts
+// a single entry of the hosts list can expand to
+const DefaultMaxTargets = 1 << 16
+
+var (
+	ErrInvalidTarget  = errors.New("invalid target")
+	ErrTooManyTargets = errors.New("target expands to too many hosts")
+)
+
+// target is an entry of the hosts list, expanded lazily:
+// a host name or IP, a CIDR prefix like 10.0.0.0/24 or fd00::/120
+// or a range of IPv4 addresses like 10.0.0.5-40 or 10.0.*.1
+type target struct {
+	size uint64
+	// hosts returns a function that yields the hosts one by one
+	hosts func() func() (string, bool)
+	// entry and err are set for the entries that can't be parsed
+	// or are too big, they are reported as not found instead of being scanned
+	entry string
+	err   error
+}
+
+// parseTarget parses an entry of the hosts list without expanding it
+func parseTarget(entry string) (*target, error) {
+	entry, ok := trimBrackets(entry)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTarget, entry)
+	}
+
+	if strings.Contains(entry, "/") {
+		return parsePrefixTarget(entry)
+	}
+
+	if octets, ok := parseOctetRanges(entry); ok {
+		return octetTarget(octets), nil
+	}
+
+	return &target{
+		size: 1,
+		hosts: func() func() (string, bool) {
+			done := false
+			return func() (string, bool) {
+				if done {
+					return "", false
+				}
+				done = true
+				return entry, true
+			}
+		},
+	}, nil
+}
+
+// parsePrefixTarget parses a CIDR prefix, the network and broadcast
+// addresses of IPv4 prefixes bigger than /31 are not scanned
+func parsePrefixTarget(entry string) (*target, error) {
+	prefix, err := netip.ParsePrefix(entry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTarget, entry)
+	}
+	prefix = prefix.Masked()
+
+	first := prefix.Addr()
+	hostBits := first.BitLen() - prefix.Bits()
+	size := uint64(math.MaxUint64)
+	if hostBits < 64 {
+		size = 1 << hostBits
+	}
+	if first.Is4() && hostBits >= 2 {
+		first = first.Next()
+		size -= 2
+	}
+
+	return &target{
+		size: size,
+		hosts: func() func() (string, bool) {
+			addr := first
+			left := size
+			return func() (string, bool) {
+				if left == 0 || !addr.IsValid() {
+					return "", false
+				}
+				h := addr.String()
+				addr = addr.Next()
+				left--
+				return h, true
+			}
+		},
+	}, nil
+}
+
+// octetRange is the range of values of an IPv4 octet
+type octetRange struct {
+	first, last int
+}
+
+// parseOctetRanges parses an IPv4 address where every octet
+// can be a number, a range like 5-40 or a * for all the values
+// it reports false if the entry is not such an address
+func parseOctetRanges(entry string) ([4]octetRange, bool) {
+	var octets [4]octetRange
+	parts := strings.Split(entry, ".")
+	if len(parts) != 4 {
+		return octets, false
+	}
+
+	isRange := false
+	for i, p := range parts {
+		switch {
+		case p == "*":
+			octets[i] = octetRange{0, 255}
+			isRange = true
+		case strings.Contains(p, "-"):
+			bounds := strings.Split(p, "-")
+			if len(bounds) != 2 {
+				return octets, false
+			}
+			first, ok1 := parseOctet(bounds[0])
+			last, ok2 := parseOctet(bounds[1])
+			if !ok1 || !ok2 || first > last {
+				return octets, false
+			}
+			octets[i] = octetRange{first, last}
+			isRange = true
+		default:
+			o, ok := parseOctet(p)
+			if !ok {
+				return octets, false
+			}
+			octets[i] = octetRange{o, o}
+		}
+	}
+
+	// plain addresses are scanned like any host
+	return octets, isRange
+}
+
+func parseOctet(s string) (int, bool) {
+	if !isNumeric(s) {
+		return 0, false
+	}
+	o, err := strconv.Atoi(s)
+	if err != nil || o > 255 {
+		return 0, false
+	}
+
+	return o, true
+}
+
+// octetTarget yields the addresses of the octet ranges
+// in order, the last octet changing first
+func octetTarget(octets [4]octetRange) *target {
+	size := uint64(1)
+	for _, o := range octets {
+		size *= uint64(o.last - o.first + 1)
+	}
+
+	return &target{
+		size: size,
+		hosts: func() func() (string, bool) {
+			cur := [4]int{octets[0].first, octets[1].first, octets[2].first, octets[3].first}
+			done := false
+			return func() (string, bool) {
+				if done {
+					return "", false
+				}
+				h := fmt.Sprintf("%d.%d.%d.%d", cur[0], cur[1], cur[2], cur[3])
+
+				// move to the next address like an odometer
+				done = true
+				for i := 3; i >= 0; i-- {
+					if cur[i] < octets[i].last {
+						cur[i]++
+						done = false
+						break
+					}
+					cur[i] = octets[i].first
+				}
+				return h, true
+			}
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package scan_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Serares/pscanner/scan"
+)
+
+func TestRunTargets(t *testing.T) {
+	testCases := []struct {
+		name        string
+		entry       string
+		expectHosts []string
+		expectLen   int
+	}{
+		{"Prefix", "127.0.0.0/30", []string{"127.0.0.1", "127.0.0.2"}, 0},
+		{"PrefixNoBroadcast", "127.0.0.4/31", []string{"127.0.0.4", "127.0.0.5"}, 0},
+		{"SingleAddressPrefix", "127.0.0.9/32", []string{"127.0.0.9"}, 0},
+		{"Range", "127.0.0.5-7", []string{"127.0.0.5", "127.0.0.6", "127.0.0.7"}, 0},
+		{"MultipleRanges", "127.0.1-2.1-2", []string{"127.0.1.1", "127.0.1.2", "127.0.2.1", "127.0.2.2"}, 0},
+		{"Wildcard", "127.0.*.1", nil, 256},
+		{"Address", "127.0.0.1", []string{"127.0.0.1"}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			hl.Add(tc.entry)
+
+			res, err := scan.Run(hl, &scan.ScanCfg{Tcp: true})
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+
+			hosts := []string{}
+			for _, r := range res {
+				hosts = append(hosts, r.Host)
+			}
+			if tc.expectLen > 0 {
+				if len(hosts) != tc.expectLen {
+					t.Errorf("Expected %d hosts, got %d instead\n", tc.expectLen, len(hosts))
+				}
+				return
+			}
+			if !reflect.DeepEqual(hosts, tc.expectHosts) {
+				t.Errorf("Expected hosts %v, got %v instead\n", tc.expectHosts, hosts)
+			}
+		})
+	}
+}
+
+func TestRunInvalidTargets(t *testing.T) {
+	testCases := []struct {
+		name      string
+		entry     string
+		cfg       *scan.ScanCfg
+		expectErr error
+	}{
+		{"InvalidPrefix", "10.0.0.0/33", &scan.ScanCfg{Tcp: true}, scan.ErrInvalidTarget},
+		{"InvalidName", "web/01", &scan.ScanCfg{Tcp: true}, scan.ErrInvalidTarget},
+		{"TooBig", "10.0.0.0/8", &scan.ScanCfg{Tcp: true}, scan.ErrTooManyTargets},
+		{"OverMaxTargets", "10.0.0.1-20", &scan.ScanCfg{Tcp: true, MaxTargets: 10}, scan.ErrTooManyTargets},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// entries of a hosts file edited by hand are not validated,
+			// they get their own result and the other hosts are scanned
+			hl := &scan.HostsList{Hosts: []string{tc.entry, "127.0.0.1"}}
+
+			res, err := scan.Run(hl, tc.cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got %q instead\n", err)
+			}
+			if len(res) != 2 {
+				t.Fatalf("Expected 2 results, got %d instead\n", len(res))
+			}
+			if res[0].Host != tc.entry || !res[0].NotFound || !errors.Is(res[0].Err, tc.expectErr) {
+				t.Errorf("Expected %s not found with error %q, got %+v instead\n", tc.entry, tc.expectErr, res[0])
+			}
+			if res[1].Host != "127.0.0.1" || res[1].NotFound {
+				t.Errorf("Expected 127.0.0.1 to be scanned, got %+v instead\n", res[1])
+			}
+		})
+	}
+}